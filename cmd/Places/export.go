@@ -0,0 +1,85 @@
+package main
+
+import (
+	"elasticTask/internal/config"
+	"elasticTask/internal/db"
+	"elasticTask/internal/export"
+	"elasticTask/pkg/types"
+	"flag"
+	"io"
+	"log"
+	"os"
+)
+
+// exportBatchSize количество мест, читаемых из хранилища за один раз
+const exportBatchSize = 1000
+
+// runExport выгружает все места в файл -o или в stdout
+func runExport(name string, args []string) error {
+	var format, output string
+	cfg, rest, err := config.Load(name, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", export.FormatNDJSON, "output format: ndjson, csv or geojson")
+		fs.StringVar(&output, "o", "", "output file (default stdout)")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %v", rest)
+	}
+
+	if _, err := export.NewWriter(format, io.Discard, nil); err != nil {
+		return usageErrorf("invalid -format %q (want ndjson, csv or geojson)", format)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	scanner, ok := store.(db.PlaceScanner)
+	if !ok {
+		return usageErrorf("store %q does not support export", cfg.Store)
+	}
+
+	var attributes []string
+	if format == export.FormatCSV {
+		if attributes, err = export.AttributeKeys(ctx, scanner, exportBatchSize); err != nil {
+			return err
+		}
+	}
+
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.Create(output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	writer, _ := export.NewWriter(format, out, attributes)
+
+	count := 0
+	err = scanner.ScanPlaces(ctx, exportBatchSize, func(places []types.Place) error {
+		for _, place := range places {
+			if err := writer.Write(place); err != nil {
+				return err
+			}
+		}
+		count += len(places)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d places", count)
+	if out != os.Stdout {
+		return out.Close()
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"elasticTask/internal/config"
+	"elasticTask/internal/db"
+	"elasticTask/internal/db/blevestore"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runIndex загружает места из файла (позиционный аргумент или -data) в elasticsearch или bleve.
+// Формат файла задается -format или определяется по расширению.
+// С -sync индекс Elasticsearch не пересоздается, а догоняет файл только измененными местами.
+func runIndex(name string, args []string) error {
+	var (
+		sync       bool
+		reportPath string
+		format     string
+	)
+	cfg, rest, err := config.Load(name, args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&sync, "sync", false, "update the existing Elasticsearch index with only added, changed and removed places")
+		fs.StringVar(&reportPath, "reject-report", "", "write rejected records (line, column, reason) to this JSON file")
+		fs.StringVar(&format, "format", "", "places file format: auto, csv, geojson, jsonl or osm (overrides -data-format)")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usageErrorf("expected at most one places file, got %v", rest)
+	}
+	if len(rest) == 1 {
+		cfg.DataPath = rest[0]
+	}
+	if format != "" {
+		cfg.DataFormat = format
+	}
+
+	if cfg.Store != config.StoreElasticsearch && cfg.Store != config.StoreBleve {
+		return usageErrorf("store %q has no index to load; use elasticsearch or bleve", cfg.Store)
+	}
+	if sync && cfg.Store != config.StoreElasticsearch {
+		return usageErrorf("-sync is supported only by the elasticsearch store")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	switch cfg.Store {
+	case config.StoreElasticsearch:
+		return indexElasticsearch(ctx, cfg, sync, reportPath)
+	default: // config.StoreBleve
+		places, err := loadPlaces(cfg, reportPath)
+		if err != nil {
+			return err
+		}
+		if len(places) < cfg.MinDocuments {
+			return fmt.Errorf("loaded %d places, expected at least %d; %s left unchanged", len(places), cfg.MinDocuments, cfg.BlevePath)
+		}
+
+		store, err := blevestore.Open(cfg.BlevePath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		if err := store.Replace(ctx, places); err != nil {
+			return err
+		}
+		log.Printf("Indexed %d places into %s", len(places), cfg.BlevePath)
+		return nil
+	}
+}
+
+// indexElasticsearch потоково читает файл с местами и загружает его в Elasticsearch целиком или в режиме sync.
+// Отчет об отклоненных записях печатается после загрузки, когда файл прочитан полностью.
+func indexElasticsearch(ctx context.Context, cfg config.Config, sync bool, reportPath string) error {
+	reader, err := openPlaces(cfg)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	store, err := newElasticsearchStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	bulk := db.BulkOptions{Buffer: cfg.BulkBuffer, ProgressEvery: cfg.ProgressEvery, MinDocuments: cfg.MinDocuments}
+	if !sync {
+		err = store.Indexeres(ctx, cfg.IndexName, reader, bulk)
+	} else {
+		var report db.SyncReport
+		report, err = store.Sync(ctx, reader, bulk)
+		log.Printf("Sync: %d added, %d changed, %d removed, %d unchanged, %d failed",
+			report.Added, report.Changed, report.Removed, report.Unchanged, report.Failed)
+	}
+
+	if reportErr := reportRejects(cfg, reader.Report(), reportPath); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	return err
+}
@@ -1,34 +1,210 @@
+// Команда Places - сервер мест и служебные команды к его хранилищу.
+//
+//	Places serve   [flags]          HTTP-сервер; индекс не меняется
+//	Places index   [flags] [file]   загрузка CSV, GeoJSON, JSON Lines или OSM XML в хранилище
+//	Places status  [flags]          количество мест и версия маппинга
+//	Places export  [flags]          выгрузка всех мест
+//
+// Коды выхода: 0 - успех, 1 - ошибка во время работы, 2 - ошибка в аргументах или настройках.
 package main
 
 import (
+	"context"
+	"elasticTask/internal/config"
+	"elasticTask/internal/csvreader"
 	"elasticTask/internal/db"
-	"elasticTask/web"
+	"elasticTask/internal/db/blevestore"
+	"elasticTask/internal/db/memstore"
+	"elasticTask/internal/placereader"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	exitOK      = 0
+	exitRuntime = 1
+	exitUsage   = 2
+)
+
+// command подкоманда Places
+type command struct {
+	name    string
+	summary string
+	run     func(name string, args []string) error
+}
+
+var commands = []command{
+	{"serve", "serve the web UI and API without touching the index", runServe},
+	{"index", "load places from a CSV, GeoJSON, JSON Lines or OSM XML file into the store", runIndex},
+	{"status", "print the number of places and the mapping version", runStatus},
+	{"export", "write all places as ndjson, csv or geojson", runExport},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
 func main() {
-	var indexName = "places"
 	log.SetFlags(0)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run выполняет подкоманду и возвращает код выхода
+func run(args []string) int {
+	// Без подкоманды (или сразу с флагами) работает serve, как раньше
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return exitOK
+	}
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		err := c.run(os.Args[0]+" "+c.name, args)
+		var usageErr *config.UsageError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			log.Printf("Error: %s", err)
+			return exitUsage
+		default:
+			log.Printf("Error: %s", err)
+			return exitRuntime
+		}
+	}
+
+	log.Printf("Error: unknown command %q", name)
+	usage()
+	return exitUsage
+}
+
+// usageErrorf ошибка в аргументах команды
+func usageErrorf(format string, a ...interface{}) error {
+	return &config.UsageError{Err: fmt.Errorf(format, a...)}
+}
+
+// signalContext отменяется по Ctrl-C или SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// newElasticsearchStore подключается к кластеру и проверяет подключение запросом info
+func newElasticsearchStore(ctx context.Context, cfg config.Config) (*db.ElasticsearchStore, error) {
+	store, err := db.NewElasticsearchStore(cfg.Elasticsearch, cfg.IndexName, time.Duration(cfg.QueryTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("creating the client: %w", err)
+	}
 
-	store, err := db.NewElasticsearchStore(indexName)
+	// Проверяем адрес, авторизацию и TLS до первого запроса к индексу
+	info, err := store.Info(ctx)
 	if err != nil {
-		log.Fatalf("Error creating the client: %s", err)
+		return nil, fmt.Errorf("connecting to Elasticsearch: %w", err)
 	}
+	log.Printf("Connected to Elasticsearch cluster %q (version %s)", info.Name, info.Version.Number)
+	return store, nil
+}
 
-	store.Indexeres(indexName)
-	fmt.Println("Server started...")
+// openStore открывает хранилище выбранного типа, не изменяя индекс: индекс Bleve открывается только для чтения
+// и должен уже существовать. Хранилище memory заполняется из файла с местами, потому что другого источника у него нет.
+func openStore(ctx context.Context, cfg config.Config) (db.Store, error) {
+	switch cfg.Store {
+	case config.StoreElasticsearch:
+		return newElasticsearchStore(ctx, cfg)
+	case config.StoreMemory:
+		places, err := loadPlaces(cfg, "")
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d places into memory", len(places))
+		return memstore.New(places), nil
+	case config.StoreBleve:
+		return blevestore.OpenReadOnly(cfg.BlevePath)
+	default:
+		return nil, usageErrorf("unknown store %q (want elasticsearch, memory or bleve)", cfg.Store)
+	}
+}
 
-	time.Sleep(1 * time.Second)
+// maxLoggedRejects количество отклоненных записей, которые печатаются в лог
+const maxLoggedRejects = 20
 
-	http.HandleFunc("/web/places", web.HtmlHandler(store))
-	http.HandleFunc("/web/recommend", web.HtmlRecommendHandler(store))
-	http.HandleFunc("/api/places", web.JsonHandler(store))
-	//http.HandleFunc("/api/recommend", web.JsonRecommendHandler(store))
-	http.HandleFunc("/api/get_token", web.TokenHandler(store))
-	http.Handle("/api/recommend", web.AuthMiddleware(http.HandlerFunc(web.JsonRecommendHandler(store))))
+// openPlaces открывает cfg.DataPath в формате cfg.DataFormat (по умолчанию - по расширению)
+func openPlaces(cfg config.Config) (*placereader.File, error) {
+	format, err := placereader.ParseFormat(cfg.DataFormat)
+	if err == nil && format == "" {
+		format, err = placereader.DetectFormat(cfg.DataPath)
+	}
+	if err != nil {
+		return nil, &config.UsageError{Err: err}
+	}
+	opts, err := cfg.CSVOptions()
+	if err != nil {
+		return nil, err
+	}
+	return placereader.Open(cfg.DataPath, format, opts)
+}
 
-	http.ListenAndServe(":8888", nil)
+// loadPlaces читает файл cfg.DataPath и печатает отклоненные записи.
+// Если reportPath не пуст, полный отчет записывается туда в JSON, в том числе при ошибке строгого режима.
+func loadPlaces(cfg config.Config, reportPath string) ([]*types.Place, error) {
+	file, err := openPlaces(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	places, err := placereader.ReadAll(file)
+	if reportErr := reportRejects(cfg, file.Report(), reportPath); reportErr != nil {
+		return nil, reportErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading places: %w", err)
+	}
+	return places, nil
+}
+
+// reportRejects печатает отклоненные записи и, если reportPath не пуст, записывает туда полный отчет в JSON
+func reportRejects(cfg config.Config, report csvreader.Report, reportPath string) error {
+	for i, rowErr := range report.Rejected {
+		if i == maxLoggedRejects {
+			log.Printf("... and %d more", len(report.Rejected)-maxLoggedRejects)
+			break
+		}
+		log.Printf("Rejected %s", rowErr)
+	}
+	if report.Rows > 0 {
+		log.Printf("Read %d of %d rows from %s (%d rejected)", report.Accepted, report.Rows, cfg.DataPath, report.RejectedRows())
+	}
+
+	if reportPath != "" && report.Rows > 0 {
+		raw, jsonErr := json.MarshalIndent(report, "", "  ")
+		if jsonErr == nil {
+			jsonErr = os.WriteFile(reportPath, raw, 0o644)
+		}
+		if jsonErr != nil {
+			return fmt.Errorf("writing reject report: %w", jsonErr)
+		}
+	}
+	return nil
 }
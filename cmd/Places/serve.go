@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"elasticTask/internal/config"
+	"elasticTask/internal/db"
+	"elasticTask/internal/db/blevestore"
+	"elasticTask/web"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// runServe запускает HTTP-сервер поверх существующего индекса
+func runServe(name string, args []string) error {
+	cfg, rest, err := config.Load(name, args, nil)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %v", rest)
+	}
+
+	store, err := openStore(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	warnIfEmpty(store)
+	fmt.Println("Server started...")
+
+	options := web.Options{
+		TemplateDir: cfg.TemplateDir,
+		PageSize:    cfg.PageSize,
+		MaxPageSize: cfg.MaxPageSize,
+	}
+	secret := []byte(cfg.JWTSecret)
+
+	http.HandleFunc("/web/places", web.HtmlHandler(store, options))
+	http.HandleFunc("/web/recommend", web.HtmlRecommendHandler(store, options))
+	http.HandleFunc("/web/search", web.HtmlSearchHandler(store, options))
+	http.HandleFunc("/api/places", web.JsonHandler(store, options))
+	http.HandleFunc("/api/search", web.JsonSearchHandler(store, options))
+	http.HandleFunc("/api/suggest", web.JsonSuggestHandler(store))
+	http.HandleFunc("/api/place", web.JsonPlaceHandler(store))
+	//http.HandleFunc("/api/recommend", web.JsonRecommendHandler(store))
+	http.HandleFunc("/api/get_token", web.TokenHandler(store, secret))
+	http.Handle("/api/recommend", web.AuthMiddleware(secret, http.HandlerFunc(web.JsonRecommendHandler(store))))
+
+	// Не каждое хранилище умеет карты, тайлы и выгрузку
+	if geoStore, ok := store.(db.GeoStore); ok {
+		http.HandleFunc("/api/places/bbox", web.JsonBoundingBoxHandler(geoStore))
+		http.HandleFunc("/api/clusters", web.JsonClustersHandler(geoStore))
+	}
+	if tileStore, ok := store.(db.TileStore); ok {
+		http.HandleFunc("/tiles/", web.TileHandler(tileStore))
+	}
+	if scanner, ok := store.(db.PlaceScanner); ok {
+		http.HandleFunc("/api/export", web.ExportHandler(scanner))
+	}
+
+	return http.ListenAndServe(cfg.Addr, nil)
+}
+
+// warnIfEmpty предупреждает, что индекс еще не загружен командой index
+func warnIfEmpty(store db.Store) {
+	switch s := store.(type) {
+	case *db.ElasticsearchStore:
+		status, err := s.Status(context.Background())
+		if errors.Is(err, db.ErrNotFound) {
+			log.Printf("WARNING: index '%s' does not exist; run the index command to load places", status.Alias)
+		} else if err != nil {
+			log.Printf("WARNING: reading index status: %s", err)
+		} else if status.MappingVersion != db.MappingVersion {
+			log.Printf("WARNING: index '%s' has mapping version %d, expected %d; run the index command to rebuild it",
+				status.Alias, status.MappingVersion, db.MappingVersion)
+		}
+	case *blevestore.Store:
+		if count, err := s.Count(); err == nil && count == 0 {
+			log.Printf("WARNING: Bleve index is empty; run the index command to load places")
+		}
+	}
+}
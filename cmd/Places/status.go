@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"elasticTask/internal/config"
+	"elasticTask/internal/db"
+	"elasticTask/internal/db/blevestore"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runStatus печатает состояние индекса; код выхода 1, если индекса нет или он недоступен
+func runStatus(name string, args []string) error {
+	var asJSON bool
+	cfg, rest, err := config.Load(name, args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&asJSON, "json", false, "print the status as JSON")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %v", rest)
+	}
+
+	ctx := context.Background()
+	var status db.IndexStatus
+	switch cfg.Store {
+	case config.StoreElasticsearch:
+		store, err := newElasticsearchStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if status, err = store.Status(ctx); err != nil {
+			return err
+		}
+	case config.StoreBleve:
+		store, err := blevestore.OpenReadOnly(cfg.BlevePath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		count, err := store.Count()
+		if err != nil {
+			return err
+		}
+		status = db.IndexStatus{Alias: cfg.BlevePath, Indices: []string{cfg.BlevePath}, Documents: int(count)}
+	default:
+		return usageErrorf("store %q has no index; use elasticsearch or bleve", cfg.Store)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	fmt.Printf("store:           %s\n", cfg.Store)
+	fmt.Printf("index:           %s\n", status.Alias)
+	fmt.Printf("indices:         %s\n", strings.Join(status.Indices, ", "))
+	fmt.Printf("documents:       %d\n", status.Documents)
+	if cfg.Store == config.StoreElasticsearch {
+		fmt.Printf("mapping version: %d (current %d)\n", status.MappingVersion, db.MappingVersion)
+	}
+	return nil
+}
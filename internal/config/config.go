@@ -0,0 +1,414 @@
+// Package config собирает настройки сервера мест из значений по умолчанию,
+// файла YAML/JSON, переменных окружения и флагов командной строки.
+//
+// Приоритет (от низшего к высшему): значения по умолчанию, файл, окружение, флаги.
+// Файл задается флагом -config или переменной PLACES_CONFIG.
+package config
+
+import (
+	"bytes"
+	"elasticTask/internal/csvreader"
+	"elasticTask/internal/db"
+	"elasticTask/internal/placereader"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Типы хранилищ
+const (
+	StoreElasticsearch = "elasticsearch"
+	StoreMemory        = "memory"
+	StoreBleve         = "bleve"
+)
+
+// Config настройки сервера
+type Config struct {
+	// Addr адрес HTTP-сервера
+	Addr string `json:"addr" yaml:"addr"`
+	// Store тип хранилища: elasticsearch, memory или bleve
+	Store string `json:"store" yaml:"store"`
+	// IndexName имя индекса Elasticsearch
+	IndexName string `json:"index_name" yaml:"index_name"`
+	// DataPath путь к файлу с местами
+	DataPath string `json:"data_path" yaml:"data_path"`
+	// DataFormat формат файла с местами: csv, geojson, jsonl, osm или auto - определить по расширению
+	DataFormat string `json:"data_format" yaml:"data_format"`
+	// StrictCSV - любая некорректная строка CSV проваливает загрузку; иначе такие строки пропускаются
+	StrictCSV bool `json:"strict_csv" yaml:"strict_csv"`
+	// CSVDelimiter разделитель колонок: tab, comma, semicolon, один символ или auto - определить по заголовку
+	CSVDelimiter string `json:"csv_delimiter" yaml:"csv_delimiter"`
+	// CSVColumns соответствие поле -> заголовок колонки для файлов с нестандартными заголовками
+	CSVColumns map[string]string `json:"csv_columns" yaml:"csv_columns"`
+	// BulkBuffer емкость очереди мест между чтением CSV и BulkIndexer
+	BulkBuffer int `json:"bulk_buffer" yaml:"bulk_buffer"`
+	// ProgressEvery печатать прогресс индексации каждые N мест; 0 - не печатать
+	ProgressEvery int `json:"progress_every" yaml:"progress_every"`
+	// MinDocuments команда index не заменяет индекс, если из файла загружено меньше мест
+	MinDocuments int `json:"min_documents" yaml:"min_documents"`
+	// BlevePath директория индекса Bleve
+	BlevePath string `json:"bleve_path" yaml:"bleve_path"`
+	// QueryTimeout предельное время одного запроса к хранилищу; 0 - без ограничения
+	QueryTimeout Duration `json:"query_timeout" yaml:"query_timeout"`
+	// TemplateDir директория HTML-шаблонов
+	TemplateDir string `json:"template_dir" yaml:"template_dir"`
+	// PageSize размер страницы по умолчанию, MaxPageSize - верхняя граница page_size
+	PageSize    int `json:"page_size" yaml:"page_size"`
+	MaxPageSize int `json:"max_page_size" yaml:"max_page_size"`
+	// JWTSecret ключ подписи токенов /api/get_token
+	JWTSecret string `json:"jwt_secret" yaml:"jwt_secret"`
+	// Elasticsearch параметры подключения к кластеру
+	Elasticsearch db.ClientConfig `json:"elasticsearch" yaml:"elasticsearch"`
+}
+
+// Duration time.Duration, которая в файле записывается строкой вида "5s"
+type Duration time.Duration
+
+// UnmarshalText разбирает строку time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText записывает длительность в формате time.Duration.String
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// String нужен flag.Value
+func (d *Duration) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set нужен flag.Value
+func (d *Duration) Set(s string) error {
+	return d.UnmarshalText([]byte(s))
+}
+
+// Default настройки, с которыми сервер работал до появления конфигурации
+func Default() Config {
+	return Config{
+		Addr:          ":8888",
+		Store:         StoreElasticsearch,
+		IndexName:     "places",
+		DataPath:      "data/data.csv",
+		BulkBuffer:    1000,
+		ProgressEvery: 1000,
+		MinDocuments:  1,
+		BlevePath:     "data/places.bleve",
+		QueryTimeout:  Duration(5 * time.Second),
+		TemplateDir:   "web",
+		PageSize:      10,
+		MaxPageSize:   100,
+		JWTSecret:     "secretkey",
+		Elasticsearch: db.DefaultClientConfig(),
+	}
+}
+
+// CSVOptions параметры чтения CSV из настроек
+func (c Config) CSVOptions() (csvreader.Options, error) {
+	delimiter, err := csvreader.ParseDelimiter(c.CSVDelimiter)
+	if err != nil {
+		return csvreader.Options{}, err
+	}
+	opts := csvreader.Options{
+		Strict:    c.StrictCSV,
+		Delimiter: delimiter,
+		Columns:   c.CSVColumns,
+	}
+	return opts, opts.Validate()
+}
+
+// indexNamePattern допустимые имена индекса Elasticsearch
+var indexNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// Validate проверяет настройки после слияния всех источников
+func (c Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+	switch c.Store {
+	case StoreElasticsearch:
+		if err := c.Elasticsearch.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("elasticsearch: %w", err))
+		}
+	case StoreMemory:
+	case StoreBleve:
+		if c.BlevePath == "" {
+			errs = append(errs, errors.New("bleve_path must not be empty for the bleve store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown store %q (want elasticsearch, memory or bleve)", c.Store))
+	}
+	if !indexNamePattern.MatchString(c.IndexName) {
+		errs = append(errs, fmt.Errorf("invalid index name %q", c.IndexName))
+	}
+	if c.DataPath == "" {
+		errs = append(errs, errors.New("data_path must not be empty"))
+	}
+	if _, err := placereader.ParseFormat(c.DataFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.CSVOptions(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.BulkBuffer < 1 {
+		errs = append(errs, fmt.Errorf("bulk_buffer must be positive, got %d", c.BulkBuffer))
+	}
+	if c.MinDocuments < 1 {
+		errs = append(errs, fmt.Errorf("min_documents must be positive, got %d", c.MinDocuments))
+	}
+	if c.ProgressEvery < 0 {
+		errs = append(errs, errors.New("progress_every must not be negative"))
+	}
+	if c.QueryTimeout < 0 {
+		errs = append(errs, errors.New("query_timeout must not be negative"))
+	}
+	if c.TemplateDir == "" {
+		errs = append(errs, errors.New("template_dir must not be empty"))
+	}
+	if c.MaxPageSize < 1 {
+		errs = append(errs, fmt.Errorf("max_page_size must be positive, got %d", c.MaxPageSize))
+	}
+	if c.PageSize < 1 || c.PageSize > c.MaxPageSize {
+		errs = append(errs, fmt.Errorf("page_size must be in 1..max_page_size (%d), got %d", c.MaxPageSize, c.PageSize))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("jwt_secret must not be empty"))
+	}
+	return errors.Join(errs...)
+}
+
+// UsageError ошибка в аргументах командной строки или в настройках, а не во время работы
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Load собирает настройки для команды name из аргументов args, окружения и файла.
+// extra регистрирует собственные флаги команды (может быть nil). Возвращает настройки и
+// позиционные аргументы. При -h возвращает flag.ErrHelp, при ошибке в аргументах
+// или настройках - *UsageError.
+func Load(name string, args []string, extra func(fs *flag.FlagSet)) (Config, []string, error) {
+	// Первый разбор: проверка аргументов и путь к файлу; значения флагов применяются позже,
+	// поверх файла и окружения
+	var path string
+	probe := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	bindFlags(fs, &probe, &path)
+	if extra != nil {
+		extra(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return Config{}, nil, err
+		}
+		return Config{}, nil, &UsageError{Err: err}
+	}
+	if path == "" {
+		path = os.Getenv("PLACES_CONFIG")
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, nil, &UsageError{Err: err}
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, nil, &UsageError{Err: err}
+	}
+
+	fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	bindFlags(fs, &cfg, &path)
+	if extra != nil {
+		extra(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, &UsageError{Err: err}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, &UsageError{Err: fmt.Errorf("invalid configuration: %w", err)}
+	}
+	return cfg, fs.Args(), nil
+}
+
+// bindFlags регистрирует флаги, пишущие в cfg
+func bindFlags(fs *flag.FlagSet, cfg *Config, path *string) {
+	fs.StringVar(path, "config", "", "YAML or JSON configuration file (env PLACES_CONFIG)")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "HTTP listen address (env PLACES_ADDR)")
+	fs.StringVar(&cfg.Store, "store", cfg.Store, "places backend: elasticsearch, memory or bleve (env PLACES_STORE)")
+	fs.StringVar(&cfg.IndexName, "index", cfg.IndexName, "Elasticsearch index name (env PLACES_INDEX)")
+	fs.StringVar(&cfg.DataPath, "data", cfg.DataPath, "file with places (env PLACES_DATA_PATH)")
+	fs.StringVar(&cfg.DataFormat, "data-format", cfg.DataFormat, "places file format: auto, csv, geojson, jsonl or osm (env PLACES_DATA_FORMAT)")
+	fs.BoolVar(&cfg.StrictCSV, "strict-csv", cfg.StrictCSV, "fail the load on any invalid CSV row instead of skipping it (env PLACES_STRICT_CSV)")
+	fs.StringVar(&cfg.CSVDelimiter, "csv-delimiter", cfg.CSVDelimiter, "CSV delimiter: auto, tab, comma, semicolon or a single character (env PLACES_CSV_DELIMITER)")
+	fs.Func("csv-columns", "CSV column mapping as field=header pairs, e.g. name=Title,latitude=Lat (env PLACES_CSV_COLUMNS)", func(s string) error {
+		columns, err := csvreader.ParseColumns(s)
+		cfg.CSVColumns = columns
+		return err
+	})
+	fs.IntVar(&cfg.BulkBuffer, "bulk-buffer", cfg.BulkBuffer, "places queued between the CSV reader and the bulk indexer (env PLACES_BULK_BUFFER)")
+	fs.IntVar(&cfg.ProgressEvery, "progress-every", cfg.ProgressEvery, "log indexing progress every N places, 0 disables (env PLACES_PROGRESS_EVERY)")
+	fs.IntVar(&cfg.MinDocuments, "min-documents", cfg.MinDocuments, "keep the current index if the file yields fewer places (env PLACES_MIN_DOCUMENTS)")
+	fs.StringVar(&cfg.BlevePath, "bleve-path", cfg.BlevePath, "directory of the Bleve index used by -store=bleve (env PLACES_BLEVE_PATH)")
+	fs.Var(&cfg.QueryTimeout, "query-timeout", "maximum duration of a single store query, 0 disables the limit (env PLACES_QUERY_TIMEOUT)")
+	fs.StringVar(&cfg.TemplateDir, "templates", cfg.TemplateDir, "directory of HTML templates (env PLACES_TEMPLATE_DIR)")
+	fs.IntVar(&cfg.PageSize, "page-size", cfg.PageSize, "default page size (env PLACES_PAGE_SIZE)")
+	fs.IntVar(&cfg.MaxPageSize, "max-page-size", cfg.MaxPageSize, "maximum page_size accepted by the API (env PLACES_MAX_PAGE_SIZE)")
+	// Секреты задаются через Func, чтобы -h не печатал значения из окружения
+	fs.Func("jwt-secret", "key used to sign API tokens (env PLACES_JWT_SECRET)", func(s string) error {
+		cfg.JWTSecret = s
+		return nil
+	})
+
+	es := &cfg.Elasticsearch
+	fs.Func("es-addresses", "comma-separated Elasticsearch node URLs (env ELASTICSEARCH_ADDRESSES, default "+strings.Join(es.Addresses, ",")+")", func(s string) error {
+		es.Addresses = db.SplitList(s)
+		return nil
+	})
+	fs.StringVar(&es.Username, "es-username", es.Username, "basic auth username (env ELASTICSEARCH_USERNAME)")
+	fs.Func("es-password", "basic auth password (env ELASTICSEARCH_PASSWORD)", func(s string) error {
+		es.Password = s
+		return nil
+	})
+	fs.Func("es-api-key", "base64-encoded API key (env ELASTICSEARCH_API_KEY)", func(s string) error {
+		es.APIKey = s
+		return nil
+	})
+	fs.StringVar(&es.CACertFile, "es-ca-cert", es.CACertFile, "PEM file of the CA that signed the cluster certificate (env ELASTICSEARCH_CA_CERT)")
+	fs.StringVar(&es.CertificateFingerprint, "es-ca-fingerprint", es.CertificateFingerprint, "SHA256 hex fingerprint of the cluster certificate (env ELASTICSEARCH_CA_FINGERPRINT)")
+	fs.Func("es-retry-on-status", "comma-separated HTTP statuses to retry (env ELASTICSEARCH_RETRY_ON_STATUS)", func(s string) error {
+		statuses, err := db.ParseStatusList(s)
+		es.RetryOnStatus = statuses
+		return err
+	})
+	fs.IntVar(&es.MaxRetries, "es-max-retries", es.MaxRetries, "maximum number of retries, 0 disables retries (env ELASTICSEARCH_MAX_RETRIES)")
+}
+
+// loadFile читает файл настроек поверх cfg; формат определяется расширением.
+// Неизвестные ключи считаются ошибкой, чтобы опечатки не терялись молча.
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("config %s: unsupported format %q (want .yaml, .yml or .json)", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv переопределяет cfg заданными переменными окружения
+func applyEnv(cfg *Config) error {
+	strs := map[string]*string{
+		"PLACES_ADDR":                  &cfg.Addr,
+		"PLACES_STORE":                 &cfg.Store,
+		"PLACES_INDEX":                 &cfg.IndexName,
+		"PLACES_DATA_PATH":             &cfg.DataPath,
+		"PLACES_DATA_FORMAT":           &cfg.DataFormat,
+		"PLACES_CSV_DELIMITER":         &cfg.CSVDelimiter,
+		"PLACES_BLEVE_PATH":            &cfg.BlevePath,
+		"PLACES_TEMPLATE_DIR":          &cfg.TemplateDir,
+		"PLACES_JWT_SECRET":            &cfg.JWTSecret,
+		"ELASTICSEARCH_USERNAME":       &cfg.Elasticsearch.Username,
+		"ELASTICSEARCH_PASSWORD":       &cfg.Elasticsearch.Password,
+		"ELASTICSEARCH_API_KEY":        &cfg.Elasticsearch.APIKey,
+		"ELASTICSEARCH_CA_CERT":        &cfg.Elasticsearch.CACertFile,
+		"ELASTICSEARCH_CA_FINGERPRINT": &cfg.Elasticsearch.CertificateFingerprint,
+	}
+	for key, dst := range strs {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"PLACES_PAGE_SIZE":          &cfg.PageSize,
+		"PLACES_MAX_PAGE_SIZE":      &cfg.MaxPageSize,
+		"PLACES_BULK_BUFFER":        &cfg.BulkBuffer,
+		"PLACES_PROGRESS_EVERY":     &cfg.ProgressEvery,
+		"PLACES_MIN_DOCUMENTS":      &cfg.MinDocuments,
+		"ELASTICSEARCH_MAX_RETRIES": &cfg.Elasticsearch.MaxRetries,
+	}
+	for key, dst := range ints {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", key, v)
+			}
+			*dst = n
+		}
+	}
+
+	if v, ok := os.LookupEnv("PLACES_STRICT_CSV"); ok {
+		strict, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("PLACES_STRICT_CSV: invalid boolean %q", v)
+		}
+		cfg.StrictCSV = strict
+	}
+	if v, ok := os.LookupEnv("PLACES_CSV_COLUMNS"); ok {
+		columns, err := csvreader.ParseColumns(v)
+		if err != nil {
+			return fmt.Errorf("PLACES_CSV_COLUMNS: %w", err)
+		}
+		cfg.CSVColumns = columns
+	}
+	if v, ok := os.LookupEnv("PLACES_QUERY_TIMEOUT"); ok {
+		if err := cfg.QueryTimeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("PLACES_QUERY_TIMEOUT: %w", err)
+		}
+	}
+	if v, ok := os.LookupEnv("ELASTICSEARCH_ADDRESSES"); ok {
+		cfg.Elasticsearch.Addresses = db.SplitList(v)
+	}
+	if v, ok := os.LookupEnv("ELASTICSEARCH_RETRY_ON_STATUS"); ok {
+		statuses, err := db.ParseStatusList(v)
+		if err != nil {
+			return fmt.Errorf("ELASTICSEARCH_RETRY_ON_STATUS: %w", err)
+		}
+		cfg.Elasticsearch.RetryOnStatus = statuses
+	}
+	return nil
+}
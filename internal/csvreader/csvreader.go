@@ -1,56 +1,493 @@
+// Package csvreader читает места из CSV-файла с проверкой каждой строки.
 package csvreader
 
 import (
+	"bufio"
+	"bytes"
 	"elasticTask/pkg/types"
 	"encoding/csv"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 )
 
-func CsvReader() []*types.Place {
+// Поля места, которые читаются из колонок файла
+const (
+	colID = iota
+	colName
+	colAddress
+	colPhone
+	colLongitude
+	colLatitude
+	numColumns
+)
+
+// Имена полей для Options.Columns
+const (
+	FieldID        = "id"
+	FieldName      = "name"
+	FieldAddress   = "address"
+	FieldPhone     = "phone"
+	FieldLongitude = "longitude"
+	FieldLatitude  = "latitude"
+)
+
+// fieldKeys имена полей в порядке колонок
+var fieldKeys = [numColumns]string{FieldID, FieldName, FieldAddress, FieldPhone, FieldLongitude, FieldLatitude}
+
+// columnNames имена колонок для отчета, если в заголовке у колонки нет имени
+var columnNames = [numColumns]string{"ID", "Name", "Address", "Phone", "Longitude", "Latitude"}
+
+// requiredFields поля, без колонок для которых файл не читается
+var requiredFields = [numColumns]bool{colID: true, colName: true, colLongitude: true, colLatitude: true}
+
+// headerAliases заголовки, которые узнаются без настройки (без учета регистра).
+// Пустой заголовок - колонка ID в выгрузке, с которой начинался проект.
+var headerAliases = [numColumns][]string{
+	colID:        {"id", ""},
+	colName:      {"name"},
+	colAddress:   {"address"},
+	colPhone:     {"phone"},
+	colLongitude: {"longitude", "lon", "lng"},
+	colLatitude:  {"latitude", "lat"},
+}
+
+// Options режим чтения
+type Options struct {
+	// Strict - любая отклоненная строка проваливает загрузку;
+	// иначе плохие строки пропускаются и попадают в отчет
+	Strict bool
+	// Delimiter разделитель колонок; 0 - определить по заголовку (табуляция, запятая или точка с запятой)
+	Delimiter rune
+	// Columns явное соответствие поле -> заголовок колонки, например {"name": "Title"};
+	// для остальных полей колонка ищется по привычным заголовкам
+	Columns map[string]string
+}
+
+// Validate проверяет имена полей в Columns
+func (o Options) Validate() error {
+	for field, header := range o.Columns {
+		if fieldIndex(field) < 0 {
+			return fmt.Errorf("unknown field %q in column mapping (want one of %s)", field, strings.Join(fieldKeys[:], ", "))
+		}
+		if strings.TrimSpace(header) == "" && field != FieldID {
+			return fmt.Errorf("empty column name for field %q", field)
+		}
+	}
+	return nil
+}
+
+// ParseDelimiter разбирает разделитель из настроек: "tab", "comma", "semicolon", один символ или "auto"/"" для автоопределения
+func ParseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	}
+
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		return 0, fmt.Errorf("invalid CSV delimiter %q", s)
+	}
+	return r[0], nil
+}
+
+// ParseColumns разбирает соответствие колонок вида "name=Title,latitude=Lat"
+func ParseColumns(s string) (map[string]string, error) {
+	columns := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid column mapping %q (want field=header)", pair)
+		}
+		columns[strings.ToLower(strings.TrimSpace(field))] = strings.TrimSpace(header)
+	}
+	return columns, nil
+}
+
+// RowError причина, по которой строка файла отклонена
+type RowError struct {
+	// Line номер строки файла, начиная с 1 (строка 1 - заголовок)
+	Line int `json:"line"`
+	// Column имя колонки; пустое, если ошибка относится ко всей строке
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
 
-	csvFilePath, err := filepath.Abs("data/data.csv")
+func (e RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d, column %s: %s", e.Line, e.Column, e.Reason)
+}
+
+// Report итог чтения файла
+type Report struct {
+	// Rows количество строк данных без заголовка
+	Rows int `json:"rows"`
+	// Accepted количество прочитанных мест
+	Accepted int `json:"accepted"`
+	// Rejected отклоненные строки; одна строка может встречаться несколько раз с разными колонками
+	Rejected []RowError `json:"rejected"`
+}
 
-	// Открываем CSV файл
-	file, err := os.Open(csvFilePath)
+// RejectedRows количество различных отклоненных строк
+func (r Report) RejectedRows() int {
+	lines := map[int]bool{}
+	for _, e := range r.Rejected {
+		lines[e.Line] = true
+	}
+	return len(lines)
+}
+
+// ValidationError строгий режим встретил отклоненные строки
+type ValidationError struct {
+	Report Report
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("%d of %d rows rejected", e.Report.RejectedRows(), e.Report.Rows)
+	if len(e.Report.Rejected) > 0 {
+		msg += "; first: " + e.Report.Rejected[0].Error()
+	}
+	return msg
+}
+
+// CsvReader читает места из CSV-файла path. Первая строка - заголовок: по нему находятся колонки полей,
+// а остальные колонки попадают в Place.Attributes.
+// Ошибка открытия или чтения файла возвращается всегда; отклоненные строки в строгом режиме
+// возвращаются как *ValidationError вместе с отчетом.
+func CsvReader(path string, opts Options) ([]*types.Place, Report, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Error opening CSV file: %s", err)
+		return nil, Report{}, fmt.Errorf("opening CSV file: %w", err)
 	}
 	defer file.Close()
 
-	// Создаем читатель CSV
-	reader := csv.NewReader(file)
-	reader.Comma = '\t' // Установка разделителя табуляции
-	records, err := reader.ReadAll()
+	places, report, err := Read(file, opts)
+	if err != nil {
+		return places, report, fmt.Errorf("%s: %w", path, err)
+	}
+	return places, report, nil
+}
+
+// Read читает места из r; см. CsvReader
+func Read(r io.Reader, opts Options) ([]*types.Place, Report, error) {
+	reader, err := NewReader(r, opts)
+	if err != nil {
+		return nil, Report{}, err
+	}
 
-	// Преобразуем записи в объекты Place
 	var places []*types.Place
-	for i, record := range records {
-		if i == 0 {
-			// Пропускаем первую строку (заголовки)
+	for {
+		place, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return places, reader.Report(), nil
+		}
+		if err != nil {
+			return nil, reader.Report(), err
+		}
+		places = append(places, place)
+	}
+}
+
+// Reader читает места по одному, не загружая файл в память целиком
+type Reader struct {
+	reader *csv.Reader
+	layout *layout
+	strict bool
+	report Report
+	// seen строка, на которой впервые встретился ID, для поиска дубликатов
+	seen map[int]int
+}
+
+// NewReader читает заголовок из r и готовит чтение строк
+func NewReader(r io.Reader, opts Options) (*Reader, error) {
+	br := bufio.NewReader(r)
+	comma := opts.Delimiter
+	if comma == 0 {
+		comma = detectDelimiter(br)
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = comma
+	// Количество полей проверяется в parseRow, чтобы короткая строка попала в отчет, а не прервала чтение
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("empty CSV file: missing header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	// С ReuseRecord следующая строка перезапишет срез заголовка
+	layout, err := newLayout(append([]string(nil), header...), opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		reader: reader,
+		layout: layout,
+		strict: opts.Strict,
+		seen:   map[int]int{},
+	}, nil
+}
+
+// Next возвращает следующее корректное место, пропуская отклоненные строки (они попадают в отчет).
+// В конце файла возвращает io.EOF, а в строгом режиме при отклоненных строках - *ValidationError.
+func (r *Reader) Next() (*types.Place, error) {
+	for {
+		record, err := r.reader.Read()
+		if errors.Is(err, io.EOF) {
+			if r.strict && len(r.report.Rejected) > 0 {
+				return nil, &ValidationError{Report: r.report}
+			}
+			return nil, io.EOF
+		}
+
+		r.report.Rows++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			r.report.Rejected = append(r.report.Rejected, RowError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()})
 			continue
 		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
 
-		// Преобразуем строки в соответствующие типы данных
-		id, _ := strconv.Atoi(record[0])
-		lat, _ := strconv.ParseFloat(record[5], 64)
-		lon, _ := strconv.ParseFloat(record[4], 64)
+		line, _ := r.reader.FieldPos(0)
+		place, rowErrs := r.layout.parseRow(record, line)
+		if place != nil {
+			if first, ok := r.seen[place.ID]; ok {
+				rowErrs = append(rowErrs, RowError{Line: line, Column: r.layout.columnName(colID),
+					Reason: fmt.Sprintf("duplicate ID %d (first seen on line %d)", place.ID, first)})
+			}
+		}
+		if len(rowErrs) > 0 {
+			r.report.Rejected = append(r.report.Rejected, rowErrs...)
+			continue
+		}
 
-		place := types.Place{
-			ID:      id,
-			Name:    record[1],
-			Address: record[2],
-			Phone:   record[3],
-			Location: types.GeoJSON{
-				Latitude:  lat,
-				Longitude: lon,
-			},
+		r.seen[place.ID] = line
+		r.report.Accepted++
+		return place, nil
+	}
+}
+
+// Report отчет о прочитанных до сих пор строках
+func (r *Reader) Report() Report {
+	return r.report
+}
+
+// detectDelimiter выбирает из табуляции, запятой и точки с запятой символ, который чаще встречается
+// в первой строке; при равенстве - табуляцию, разделитель исходного файла
+func detectDelimiter(br *bufio.Reader) rune {
+	peek, _ := br.Peek(64 << 10)
+	if i := bytes.IndexByte(peek, '\n'); i >= 0 {
+		peek = peek[:i]
+	}
+
+	best, bestCount := '\t', bytes.Count(peek, []byte{'\t'})
+	for _, c := range []rune{',', ';'} {
+		if n := bytes.Count(peek, []byte{byte(c)}); n > bestCount {
+			best, bestCount = c, n
+		}
+	}
+	return best
+}
+
+// layout расположение полей места в колонках файла
+type layout struct {
+	header []string
+	// index номер колонки каждого поля; -1 - колонки нет
+	index [numColumns]int
+	// extra колонки, которые не относятся к полям места и попадают в Attributes
+	extra []int
+}
+
+// newLayout находит колонки полей по заголовку header и явному соответствию columns
+func newLayout(header []string, columns map[string]string) (*layout, error) {
+	if len(header) > 0 {
+		// Excel и некоторые выгрузки начинают UTF-8 файл с BOM
+		header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+	}
+
+	l := &layout{header: header}
+	for i := range l.index {
+		l.index[i] = -1
+	}
+
+	byName := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, ok := byName[key]; !ok {
+			byName[key] = i
+		}
+	}
+
+	if err := (Options{Columns: columns}).Validate(); err != nil {
+		return nil, err
+	}
+	for field, name := range columns {
+		col := fieldIndex(field)
+		i, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("column %q mapped to field %s is not in the header %q", name, field, header)
+		}
+		l.index[col] = i
+	}
+
+	for col, aliases := range headerAliases {
+		if l.index[col] >= 0 {
+			continue
+		}
+		for _, alias := range aliases {
+			if i, ok := byName[alias]; ok {
+				l.index[col] = i
+				break
+			}
+		}
+	}
+
+	var missing []string
+	used := map[int]bool{}
+	for col, i := range l.index {
+		if i >= 0 {
+			used[i] = true
+		} else if requiredFields[col] {
+			missing = append(missing, fieldKeys[col])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required columns: %s (header: %q)", strings.Join(missing, ", "), header)
+	}
+
+	for i := range header {
+		if !used[i] {
+			l.extra = append(l.extra, i)
+		}
+	}
+	return l, nil
+}
+
+// fieldIndex номер поля по имени из Options.Columns; -1, если такого поля нет
+func fieldIndex(field string) int {
+	for col, key := range fieldKeys {
+		if strings.EqualFold(key, field) {
+			return col
 		}
+	}
+	return -1
+}
+
+// columnName имя колонки поля col для отчета
+func (l *layout) columnName(col int) string {
+	if name := strings.TrimSpace(l.header[l.index[col]]); name != "" {
+		return name
+	}
+	return columnNames[col]
+}
+
+// attributeName ключ Attributes для дополнительной колонки i; колонка без заголовка называется column_<номер>
+func (l *layout) attributeName(i int) string {
+	if name := strings.TrimSpace(l.header[i]); name != "" {
+		return name
+	}
+	return fmt.Sprintf("column_%d", i+1)
+}
 
-		places = append(places, &place)
+// value значение поля col; пустая строка, если колонки нет
+func (l *layout) value(record []string, col int) string {
+	if l.index[col] < 0 {
+		return ""
+	}
+	return record[l.index[col]]
+}
+
+// parseRow проверяет строку и собирает все ее ошибки, а не только первую
+func (l *layout) parseRow(record []string, line int) (*types.Place, []RowError) {
+	if len(record) != len(l.header) {
+		return nil, []RowError{{Line: line, Reason: fmt.Sprintf("expected %d columns, got %d", len(l.header), len(record))}}
+	}
 
+	var errs []RowError
+	fail := func(col int, format string, a ...interface{}) {
+		errs = append(errs, RowError{Line: line, Column: l.columnName(col), Reason: fmt.Sprintf(format, a...)})
+	}
+
+	idStr := l.value(record, colID)
+	id, err := strconv.Atoi(strings.TrimSpace(idStr))
+	if err != nil {
+		fail(colID, "invalid integer %q", idStr)
+	} else if id < 0 {
+		fail(colID, "negative ID %d", id)
+	}
+
+	if strings.TrimSpace(l.value(record, colName)) == "" {
+		fail(colName, "required field is empty")
+	}
+
+	lon := parseCoordinate(l.value(record, colLongitude), colLongitude, 180, fail)
+	lat := parseCoordinate(l.value(record, colLatitude), colLatitude, 90, fail)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	place := &types.Place{
+		ID:      id,
+		Name:    l.value(record, colName),
+		Address: l.value(record, colAddress),
+		Phone:   l.value(record, colPhone),
+		Location: types.GeoJSON{
+			Latitude:  lat,
+			Longitude: lon,
+		},
+	}
+
+	for _, i := range l.extra {
+		if record[i] == "" {
+			continue
+		}
+		if place.Attributes == nil {
+			place.Attributes = map[string]string{}
+		}
+		place.Attributes[l.attributeName(i)] = record[i]
+	}
+	return place, nil
+}
+
+// parseCoordinate читает координату поля col и проверяет, что она в пределах [-limit, limit]
+func parseCoordinate(value string, col int, limit float64, fail func(col int, format string, a ...interface{})) float64 {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		fail(col, "required field is empty")
+		return 0
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		fail(col, "invalid number %q", s)
+		return 0
+	}
+	if v < -limit || v > limit {
+		fail(col, "%v is out of range [-%v, %v]", v, limit, limit)
 	}
-	return places
+	return v
 }
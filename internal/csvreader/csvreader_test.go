@@ -0,0 +1,20 @@
+package csvreader
+
+import (
+	"strings"
+	"testing"
+)
+
+const duplicateIDs = "\tName\tAddress\tPhone\tLongitude\tLatitude\n" +
+	"1\tSMETANA\tul. Pravdy, 8\t\t37.61\t55.75\n" +
+	"1\tRodnik\tul. Lesnaja, 5\t\t37.59\t55.78\n"
+
+func TestReadDuplicateIDs(t *testing.T) {
+	places, report, err := Read(strings.NewReader(duplicateIDs), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || len(report.Rejected) != 1 || report.Rejected[0].Line != 3 {
+		t.Errorf("got %d places, rejected %+v; want the second row rejected", len(places), report.Rejected)
+	}
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// indexVersionLayout время создания версии индекса в имени <alias>_v<время>;
+// лексикографический порядок имен совпадает с хронологическим
+const indexVersionLayout = "20060102150405"
+
+// versionedIndexName имя новой версии индекса алиаса alias
+func versionedIndexName(alias string, t time.Time) string {
+	return alias + "_v" + t.UTC().Format(indexVersionLayout)
+}
+
+// refreshIndex делает загруженные документы видимыми для поиска
+func (es ElasticsearchStore) refreshIndex(ctx context.Context, indexName string) error {
+	res, err := es.client.Indices.Refresh(
+		es.client.Indices.Refresh.WithContext(ctx),
+		es.client.Indices.Refresh.WithIndex(indexName),
+	)
+	if err != nil {
+		return transportError("refresh index", err)
+	}
+	if res.IsError() {
+		return responseError("refresh index", res)
+	}
+	res.Body.Close()
+	return nil
+}
+
+// countDocuments возвращает количество документов в индексе или алиасе
+func (es ElasticsearchStore) countDocuments(ctx context.Context, indexName string) (int, error) {
+	res, err := es.client.Count(
+		es.client.Count.WithContext(ctx),
+		es.client.Count.WithIndex(indexName),
+	)
+	if err != nil {
+		return 0, transportError("count documents", err)
+	}
+	if res.IsError() {
+		return 0, responseError("count documents", res)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("count documents: decoding response: %w", err)
+	}
+	return body.Count, nil
+}
+
+// aliasTargets возвращает индексы, на которые указывает алиас alias.
+// legacy - true, если alias не алиас, а обычный индекс (так индексировали до появления версий).
+func (es ElasticsearchStore) aliasTargets(ctx context.Context, alias string) (targets []string, legacy bool, err error) {
+	res, err := es.client.Indices.GetAlias(
+		es.client.Indices.GetAlias.WithContext(ctx),
+		es.client.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return nil, false, transportError("get alias", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+
+		existsRes, err := es.client.Indices.Exists([]string{alias}, es.client.Indices.Exists.WithContext(ctx))
+		if err != nil {
+			return nil, false, transportError("check index existence", err)
+		}
+		existsRes.Body.Close()
+		return nil, existsRes.StatusCode == http.StatusOK, nil
+	}
+	if res.IsError() {
+		return nil, false, responseError("get alias", res)
+	}
+	defer res.Body.Close()
+
+	// {"places_v20240101000000": {"aliases": {"places": {}}}}
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, false, fmt.Errorf("get alias: decoding response: %w", err)
+	}
+	for index := range body {
+		targets = append(targets, index)
+	}
+	sort.Strings(targets)
+	return targets, false, nil
+}
+
+// swapAlias одним запросом _aliases переводит алиас alias на indexName и возвращает прежние индексы алиаса.
+// Обычный индекс с именем alias удаляется в том же запросе, чтобы имя освободилось под алиас.
+func (es ElasticsearchStore) swapAlias(ctx context.Context, alias, indexName string) ([]string, error) {
+	previous, legacy, err := es.aliasTargets(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []map[string]interface{}
+	if legacy {
+		log.Printf("Index '%s' is not an alias; it will be replaced", alias)
+		actions = append(actions, map[string]interface{}{
+			"remove_index": map[string]interface{}{"index": alias},
+		})
+	}
+	for _, index := range previous {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]interface{}{"index": index, "alias": alias},
+		})
+	}
+	actions = append(actions, map[string]interface{}{
+		"add": map[string]interface{}{"index": indexName, "alias": alias},
+	})
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := es.client.Indices.UpdateAliases(
+		bytes.NewReader(body),
+		es.client.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, transportError("update aliases", err)
+	}
+	if res.IsError() {
+		return nil, responseError("update aliases", res)
+	}
+	res.Body.Close()
+	return previous, nil
+}
+
+// pruneIndexVersions удаляет версии индекса алиаса alias, кроме перечисленных в keep
+func (es ElasticsearchStore) pruneIndexVersions(ctx context.Context, alias string, keep []string) error {
+	versions, err := es.indexVersions(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	kept := map[string]bool{}
+	for _, index := range keep {
+		kept[index] = true
+	}
+
+	var stale []string
+	for _, index := range versions {
+		if !kept[index] {
+			stale = append(stale, index)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	if err := es.deleteIndices(ctx, stale); err != nil {
+		return err
+	}
+	log.Printf("Deleted old index versions: %s", strings.Join(stale, ", "))
+	return nil
+}
+
+// indexVersions возвращает имена версий индекса алиаса alias от старой к новой
+func (es ElasticsearchStore) indexVersions(ctx context.Context, alias string) ([]string, error) {
+	res, err := es.client.Cat.Indices(
+		es.client.Cat.Indices.WithContext(ctx),
+		es.client.Cat.Indices.WithIndex(alias+"_v*"),
+		es.client.Cat.Indices.WithH("index"),
+		es.client.Cat.Indices.WithFormat("json"),
+	)
+	if err != nil {
+		return nil, transportError("list index versions", err)
+	}
+	if res.IsError() {
+		return nil, responseError("list index versions", res)
+	}
+	defer res.Body.Close()
+
+	var rows []struct {
+		Index string `json:"index"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("list index versions: decoding response: %w", err)
+	}
+
+	versions := make([]string, 0, len(rows))
+	for _, row := range rows {
+		versions = append(versions, row.Index)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// deleteIndices удаляет индексы
+func (es ElasticsearchStore) deleteIndices(ctx context.Context, indices []string) error {
+	req := esapi.IndicesDeleteRequest{Index: indices}
+
+	res, err := req.Do(ctx, es.client)
+	if err != nil {
+		return transportError("delete index", err)
+	}
+	if res.IsError() {
+		return responseError("delete index", res)
+	}
+	res.Body.Close()
+	return nil
+}
+
+// dropFailedIndex удаляет недогруженную версию индекса. Контекст загрузки мог быть
+// отменен, поэтому удаление выполняется в своем контексте; ошибка только логируется.
+func (es ElasticsearchStore) dropFailedIndex(indexName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := es.deleteIndices(ctx, []string{indexName}); err != nil {
+		log.Printf("WARNING: deleting incomplete index '%s': %s", indexName, err)
+	}
+}
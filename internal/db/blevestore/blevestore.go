@@ -0,0 +1,463 @@
+// Package blevestore реализует хранилище мест во встроенном индексе Bleve в локальной директории.
+// Подходит для небольших развертываний без кластера Elasticsearch.
+package blevestore
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// sourceField хранит исходный JSON места, как _source в Elasticsearch
+const sourceField = "source"
+
+// clusterPageSize количество мест, которые читаются из индекса за раз при построении кластеров
+const clusterPageSize = 10000
+
+// Store хранилище мест поверх индекса Bleve
+type Store struct {
+	index bleve.Index
+}
+
+var (
+	_ db.Store        = (*Store)(nil)
+	_ db.GeoStore     = (*Store)(nil)
+	_ db.PlaceScanner = (*Store)(nil)
+)
+
+// indexMapping описывает поля места: анализ текста для name и address,
+// keyword-копию name для сортировки, числовой id и geo_point location
+func indexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = standard.Name
+	text.Store = false
+
+	nameKeyword := bleve.NewTextFieldMapping()
+	nameKeyword.Name = "name_keyword"
+	nameKeyword.Analyzer = keyword.Name
+	nameKeyword.Store = false
+
+	id := bleve.NewNumericFieldMapping()
+	id.Store = false
+
+	location := bleve.NewGeoPointFieldMapping()
+	location.Store = false
+
+	source := bleve.NewTextFieldMapping()
+	source.Index = false
+	source.IncludeInAll = false
+
+	place := bleve.NewDocumentStaticMapping()
+	place.AddFieldMappingsAt("id", id)
+	place.AddFieldMappingsAt("name", text, nameKeyword)
+	place.AddFieldMappingsAt("address", text)
+	place.AddFieldMappingsAt("location", location)
+	place.AddFieldMappingsAt(sourceField, source)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = place
+	m.DefaultAnalyzer = standard.Name
+	return m
+}
+
+// Open открывает индекс в директории path или создает новый, если его там нет
+func Open(path string) (*Store, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, indexMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open bleve index %q: %w", path, err)
+	}
+	return &Store{index: index}, nil
+}
+
+// OpenReadOnly открывает существующий индекс в директории path только для чтения.
+// Если индекса там нет, возвращает ошибку, оборачивающую db.ErrNotFound, и ничего не создает.
+// Записывать в такой индекс нельзя: он для команд, которые только читают.
+func OpenReadOnly(path string) (*Store, error) {
+	index, err := bleve.OpenUsing(path, map[string]interface{}{"read_only": true})
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return nil, fmt.Errorf("bleve index %q: %w; run the index command to create it", path, db.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open bleve index %q: %w", path, err)
+	}
+	return &Store{index: index}, nil
+}
+
+// Close закрывает индекс
+func (s *Store) Close() error {
+	return s.index.Close()
+}
+
+// Count возвращает количество мест в индексе
+func (s *Store) Count() (uint64, error) {
+	return s.index.DocCount()
+}
+
+// Load индексирует места пачками по 1000
+func (s *Store) Load(places []*types.Place) error {
+	batch := s.index.NewBatch()
+	for _, place := range places {
+		source, err := json.Marshal(place)
+		if err != nil {
+			return fmt.Errorf("encode place %d: %w", place.ID, err)
+		}
+
+		doc := map[string]interface{}{
+			"id":      place.ID,
+			"name":    place.Name,
+			"address": place.Address,
+			"location": map[string]interface{}{
+				"lat": place.Location.Latitude,
+				"lon": place.Location.Longitude,
+			},
+			sourceField: string(source),
+		}
+		if err := batch.Index(strconv.Itoa(place.ID), doc); err != nil {
+			return fmt.Errorf("index place %d: %w", place.ID, err)
+		}
+
+		if batch.Size() >= 1000 {
+			if err := s.index.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return s.index.Batch(batch)
+}
+
+// Replace заменяет содержимое индекса местами places: места, которых нет в places, удаляются
+func (s *Store) Replace(ctx context.Context, places []*types.Place) error {
+	keep := make(map[int]bool, len(places))
+	for _, place := range places {
+		keep[place.ID] = true
+	}
+
+	var stale []string
+	err := s.ScanPlaces(ctx, 1000, func(batch []types.Place) error {
+		for _, place := range batch {
+			if !keep[place.ID] {
+				stale = append(stale, strconv.Itoa(place.ID))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := s.index.NewBatch()
+	for _, id := range stale {
+		batch.Delete(id)
+	}
+	if err := s.index.Batch(batch); err != nil {
+		return err
+	}
+	return s.Load(places)
+}
+
+// search выполняет запрос и разбирает места из сохраненного исходного JSON
+func (s *Store) search(ctx context.Context, req *bleve.SearchRequest) ([]types.Place, *bleve.SearchResult, error) {
+	req.Fields = []string{sourceField}
+
+	res, err := s.index.SearchInContext(ctx, req)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, nil, fmt.Errorf("bleve search: %w: %s", db.ErrTimeout, err)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("bleve search: %w", err)
+	}
+
+	places := make([]types.Place, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		source, ok := hit.Fields[sourceField].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("bleve search: document %q has no stored source", hit.ID)
+		}
+
+		var place types.Place
+		if err := json.Unmarshal([]byte(source), &place); err != nil {
+			return nil, nil, fmt.Errorf("bleve search: document %q: %w", hit.ID, err)
+		}
+		places = append(places, place)
+	}
+	return places, res, nil
+}
+
+// sortOrder переводит db.SortOption в сортировку Bleve; nil - порядок по релевантности
+func sortOrder(option db.SortOption) (search.SortOrder, error) {
+	switch option.Field {
+	case db.SortByName:
+		return search.SortOrder{&search.SortField{Field: "name_keyword", Desc: option.Desc}}, nil
+	case db.SortByID:
+		return search.SortOrder{&search.SortField{Field: "id", Desc: option.Desc, Type: search.SortFieldAsNumber}}, nil
+	case db.SortByDistance:
+		if option.Origin == nil {
+			return nil, fmt.Errorf("%w: distance sort requires an origin", db.ErrBadRequest)
+		}
+		byDistance, err := search.NewSortGeoDistance("location", "m", option.Origin.Longitude, option.Origin.Latitude, option.Desc)
+		if err != nil {
+			return nil, err
+		}
+		return search.SortOrder{byDistance}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// GetPlaces Возвращает страницу мест в порядке sort; без сортировки - по id, в порядке загрузки,
+// как match_all в Elasticsearch и memstore. Порядок по релевантности у match_all в Bleve случаен.
+func (s *Store) GetPlaces(ctx context.Context, limit int, offset int, option db.SortOption) ([]types.Place, int, error) {
+	if option.Field == "" {
+		option = db.SortOption{Field: db.SortByID}
+	}
+	order, err := sortOrder(option)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), limit, offset, false)
+	req.SortByCustom(order)
+
+	places, res, err := s.search(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return places, int(res.Total), nil
+}
+
+// GetPlacesAfter Возвращает следующую страницу мест в порядке id. Курсор - значения сортировки последнего места.
+func (s *Store) GetPlacesAfter(ctx context.Context, limit int, cursor string) ([]types.Place, int, string, error) {
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), limit, 0, false)
+	req.SortByCustom(search.SortOrder{
+		&search.SortField{Field: "id", Type: search.SortFieldAsNumber},
+		&search.SortDocID{},
+	})
+
+	if cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, 0, "", db.ErrInvalidCursor
+		}
+		if err := json.Unmarshal(raw, &req.SearchAfter); err != nil || len(req.SearchAfter) != 2 {
+			return nil, 0, "", db.ErrInvalidCursor
+		}
+	}
+
+	places, res, err := s.search(ctx, req)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if len(res.Hits) < limit {
+		return places, int(res.Total), "", nil
+	}
+
+	raw, err := json.Marshal(res.Hits[len(res.Hits)-1].Sort)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return places, int(res.Total), base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// GetPlace Находит место по ID
+func (s *Store) GetPlace(ctx context.Context, id int) (types.Place, error) {
+	req := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery([]string{strconv.Itoa(id)}), 1, 0, false)
+	places, _, err := s.search(ctx, req)
+	if err != nil {
+		return types.Place{}, err
+	}
+	if len(places) == 0 {
+		return types.Place{}, fmt.Errorf("place %d: %w", id, db.ErrNotFound)
+	}
+	return places[0], nil
+}
+
+// GetRecommendPlaces Находит ближайшие места сортировкой по geo-расстоянию, при заданном radius - только внутри него
+func (s *Store) GetRecommendPlaces(ctx context.Context, limit int, lat, lon float64, radius string) ([]types.RecommendedPlace, int, error) {
+	var q query.Query = bleve.NewMatchAllQuery()
+	if radius != "" {
+		// Bleve понимает не все единицы Elasticsearch (например, nmi), поэтому радиус передается в метрах
+		meters, err := geo.ParseDistance(radius)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %s", db.ErrBadRequest, err)
+		}
+		distance := bleve.NewGeoDistanceQuery(lon, lat, strconv.FormatFloat(meters, 'f', -1, 64)+"m")
+		distance.SetField("location")
+		q = distance
+	}
+
+	origin := types.GeoJSON{Latitude: lat, Longitude: lon}
+	order, err := sortOrder(db.SortOption{Field: db.SortByDistance, Origin: &origin})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := bleve.NewSearchRequestOptions(q, limit, 0, false)
+	req.SortByCustom(order)
+
+	places, res, err := s.search(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	recommended := make([]types.RecommendedPlace, 0, len(places))
+	for _, place := range places {
+		recommended = append(recommended, types.RecommendedPlace{
+			Place:    place,
+			Distance: geo.Distance(origin, place.Location),
+		})
+	}
+	return recommended, int(res.Total), nil
+}
+
+// fieldMatch полнотекстовый запрос по полю с допуском одной опечатки в слове
+func fieldMatch(field, text string, boost float64) query.Query {
+	q := bleve.NewMatchQuery(text)
+	q.SetField(field)
+	q.SetFuzziness(1)
+	q.SetOperator(query.MatchQueryOperatorAnd)
+	q.SetBoost(boost)
+	return q
+}
+
+// Search Полнотекстовый поиск по названию и адресу с допуском опечаток; название весит вдвое больше
+func (s *Store) Search(ctx context.Context, text string, limit int, offset int) ([]types.Place, int, error) {
+	q := bleve.NewDisjunctionQuery(
+		fieldMatch("name", text, 2),
+		fieldMatch("address", text, 1),
+	)
+
+	places, res, err := s.search(ctx, bleve.NewSearchRequestOptions(q, limit, offset, false))
+	if err != nil {
+		return nil, 0, err
+	}
+	return places, int(res.Total), nil
+}
+
+// Suggest Подсказки: каждое слово ввода - начало слова в названии или адресе.
+// При заданном origin ближайшие места идут первыми.
+func (s *Store) Suggest(ctx context.Context, prefix string, limit int, origin *types.GeoJSON) ([]types.Suggestion, error) {
+	words := strings.Fields(strings.ToLower(prefix))
+	if len(words) == 0 {
+		return []types.Suggestion{}, nil
+	}
+
+	conjuncts := make([]query.Query, 0, len(words))
+	for _, word := range words {
+		name := bleve.NewPrefixQuery(word)
+		name.SetField("name")
+		name.SetBoost(3)
+		address := bleve.NewPrefixQuery(word)
+		address.SetField("address")
+		conjuncts = append(conjuncts, bleve.NewDisjunctionQuery(name, address))
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), limit, 0, false)
+	if origin != nil {
+		order, err := sortOrder(db.SortOption{Field: db.SortByDistance, Origin: origin})
+		if err != nil {
+			return nil, err
+		}
+		req.SortByCustom(order)
+	}
+
+	places, _, err := s.search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]types.Suggestion, 0, len(places))
+	for _, place := range places {
+		suggestions = append(suggestions, types.Suggestion{
+			ID:       place.ID,
+			Name:     place.Name,
+			Address:  place.Address,
+			Location: place.Location,
+		})
+	}
+	return suggestions, nil
+}
+
+// boundingBoxQuery запрос мест внутри прямоугольника карты
+func boundingBoxQuery(topLeft, bottomRight types.GeoJSON) query.Query {
+	q := bleve.NewGeoBoundingBoxQuery(topLeft.Longitude, topLeft.Latitude, bottomRight.Longitude, bottomRight.Latitude)
+	q.SetField("location")
+	return q
+}
+
+// GetPlacesInBoundingBox Находит места внутри прямоугольника карты
+func (s *Store) GetPlacesInBoundingBox(ctx context.Context, topLeft, bottomRight types.GeoJSON, limit int) ([]types.Place, int, error) {
+	places, res, err := s.search(ctx, bleve.NewSearchRequestOptions(boundingBoxQuery(topLeft, bottomRight), limit, 0, false))
+	if err != nil {
+		return nil, 0, err
+	}
+	return places, int(res.Total), nil
+}
+
+// GetClusters Группирует места в ячейки geohash. В Bleve нет гео-агрегаций,
+// поэтому места читаются из индекса страницами по clusterPageSize и группируются в памяти.
+// Как в geohash_grid, ячейки идут от самых наполненных; total - количество сгруппированных мест.
+func (s *Store) GetClusters(ctx context.Context, precision int, box *types.BoundingBox) ([]types.Cluster, int, error) {
+	var q query.Query = bleve.NewMatchAllQuery()
+	if box != nil {
+		q = boundingBoxQuery(box.TopLeft, box.BottomRight)
+	}
+
+	grid := geo.NewClusterGrid(precision)
+	var after []string
+	for {
+		req := bleve.NewSearchRequestOptions(q, clusterPageSize, 0, false)
+		req.SortByCustom(search.SortOrder{&search.SortDocID{}})
+		req.SearchAfter = after
+
+		places, res, err := s.search(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, place := range places {
+			grid.Add(place.Location)
+		}
+
+		if len(res.Hits) < clusterPageSize {
+			break
+		}
+		after = res.Hits[len(res.Hits)-1].Sort
+	}
+
+	return grid.Clusters(), grid.Total(), nil
+}
+
+// ScanPlaces Обходит все места пачками по batchSize в порядке id
+func (s *Store) ScanPlaces(ctx context.Context, batchSize int, fn func([]types.Place) error) error {
+	cursor := ""
+	for {
+		places, _, next, err := s.GetPlacesAfter(ctx, batchSize, cursor)
+		if err != nil {
+			return err
+		}
+		if err := fn(places); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,371 @@
+package blevestore
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// origin точка в центре Москвы, от которой считаются расстояния; от нее записан ответ geo_distance_response.json
+var origin = types.GeoJSON{Latitude: 55.7522, Longitude: 37.6156}
+
+// readFixture разбирает записанный ответ _search Elasticsearch
+func readFixture(t *testing.T, path string) *db.SearchResult {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	result, err := db.DecodeSearchResponse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// newTestStore загружает в Bleve во временной директории места записанного ответа match_all
+// индекса Elasticsearch и возвращает этот ответ: с ним сравниваются результаты хранилища.
+func newTestStore(t *testing.T) (*Store, *db.SearchResult) {
+	t.Helper()
+
+	fixture := readFixture(t, "../fakestore/testdata/search_response.json")
+
+	store, err := Open(filepath.Join(t.TempDir(), "places.bleve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	places := fixture.Places()
+	input := make([]*types.Place, 0, len(places))
+	for i := range places {
+		input = append(input, &places[i])
+	}
+	if err := store.Load(input); err != nil {
+		t.Fatal(err)
+	}
+	return store, fixture
+}
+
+func ids(places []types.Place) []int {
+	result := make([]int, 0, len(places))
+	for _, place := range places {
+		result = append(result, place.ID)
+	}
+	return result
+}
+
+func sortedIDs(places []types.Place) []int {
+	result := ids(places)
+	sort.Ints(result)
+	return result
+}
+
+func TestGetPlaces(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	// Первая страница без сортировки совпадает с записанным ответом: те же места, поля и total
+	got, total, err := store.GetPlaces(ctx, 20, 0, db.SortOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != fixture.Total || !reflect.DeepEqual(got, fixture.Places()) {
+		t.Errorf("got %v of %d, want %v of %d", ids(got), total, ids(fixture.Places()), fixture.Total)
+	}
+
+	byName := fixture.Places()
+	sort.SliceStable(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+	tests := []struct {
+		option db.SortOption
+		less   func(a, b types.Place) bool
+	}{
+		{db.SortOption{Field: db.SortByID, Desc: true}, func(a, b types.Place) bool { return a.ID > b.ID }},
+		{db.SortOption{Field: db.SortByName}, func(a, b types.Place) bool { return a.Name < b.Name }},
+		{db.SortOption{Field: db.SortByName, Desc: true}, func(a, b types.Place) bool { return a.Name > b.Name }},
+		{db.SortOption{Field: db.SortByDistance, Origin: &origin}, func(a, b types.Place) bool {
+			return geo.Distance(origin, a.Location) < geo.Distance(origin, b.Location)
+		}},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s desc=%v", tt.option.Field, tt.option.Desc)
+		got, total, err := store.GetPlaces(ctx, 20, 0, tt.option)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if total != fixture.Total || !reflect.DeepEqual(sortedIDs(got), sortedIDs(fixture.Places())) {
+			t.Fatalf("%s: got %v of %d", name, ids(got), total)
+		}
+		for i := 1; i < len(got); i++ {
+			if tt.less(got[i], got[i-1]) {
+				t.Errorf("%s: place %d (id %d) is out of order: %v", name, i, got[i].ID, ids(got))
+				break
+			}
+		}
+
+		// Страница со смещением - продолжение той же последовательности
+		page, _, err := store.GetPlaces(ctx, 5, 5, tt.option)
+		if err != nil || len(page) != 5 {
+			t.Fatalf("%s: offset page %v, err %v", name, ids(page), err)
+		}
+		for i := range page {
+			if tt.less(page[i], got[5+i]) || tt.less(got[5+i], page[i]) {
+				t.Errorf("%s: offset page %v, want %v", name, ids(page), ids(got[5:10]))
+				break
+			}
+		}
+	}
+
+	// Страница за концом - пустой слайс, а не nil: в JSON это [], как hits у Elasticsearch
+	got, total, err = store.GetPlaces(ctx, 10, 100, db.SortOption{})
+	if err != nil || got == nil || len(got) != 0 || total != fixture.Total {
+		t.Errorf("offset past the end: %v of %d, err %v", got, total, err)
+	}
+
+	if _, _, err := store.GetPlaces(ctx, 10, 0, db.SortOption{Field: db.SortByDistance}); !errors.Is(err, db.ErrBadRequest) {
+		t.Errorf("distance sort without origin: got %v, want ErrBadRequest", err)
+	}
+}
+
+func TestGetPlacesAfter(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	var walked []types.Place
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor pagination does not end")
+		}
+		places, total, next, err := store.GetPlacesAfter(ctx, 8, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != fixture.Total {
+			t.Errorf("total %d, want %d", total, fixture.Total)
+		}
+		walked = append(walked, places...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	// Как с point-in-time в Elasticsearch: каждое место ровно один раз, в порядке id
+	if !reflect.DeepEqual(walked, fixture.Places()) {
+		t.Errorf("walked %v, want %v", ids(walked), ids(fixture.Places()))
+	}
+
+	for _, cursor := range []string{"garbage", "W10"} {
+		if _, _, _, err := store.GetPlacesAfter(ctx, 8, cursor); !errors.Is(err, db.ErrInvalidCursor) || !errors.Is(err, db.ErrBadRequest) {
+			t.Errorf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestGetPlace(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	got, err := store.GetPlace(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fixture.Hits[3].Place; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := store.GetPlace(ctx, 999); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("unknown id: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetRecommendPlaces(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	// Записанный ответ Elasticsearch с сортировкой _geo_distance от origin по тем же местам
+	recorded := readFixture(t, "../testdata/geo_distance_response.json")
+	var indexed struct {
+		DocCount int `json:"doc_count"`
+	}
+	if err := json.Unmarshal(recorded.Aggregations["indexed"], &indexed); err != nil {
+		t.Fatal(err)
+	}
+
+	got, total, err := store.GetRecommendPlaces(ctx, len(recorded.Hits), origin.Latitude, origin.Longitude, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Без радиуса total - все места индекса, как агрегация global у Elasticsearch
+	if total != indexed.DocCount || len(got) != len(recorded.Hits) {
+		t.Fatalf("got %d places of %d, want %d of %d", len(got), total, len(recorded.Hits), indexed.DocCount)
+	}
+	for i, hit := range recorded.Hits {
+		distance, _ := hit.Sort[0].(float64)
+		if !reflect.DeepEqual(got[i].Place, hit.Place) || math.Abs(got[i].Distance-distance) > 0.01 {
+			t.Errorf("place %d: got id %d at %.3fm, want id %d at %.3fm", i, got[i].ID, got[i].Distance, hit.Place.ID, distance)
+		}
+	}
+
+	// С радиусом total - места внутри него; единицы те же, что принимает Elasticsearch
+	for _, radius := range []string{"5km", "2.7nmi", "1.2km", "1NM", "5000", "10m"} {
+		meters, err := geo.ParseDistance(radius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		for _, place := range fixture.Places() {
+			if geo.Distance(origin, place.Location) <= meters {
+				want++
+			}
+		}
+
+		got, total, err := store.GetRecommendPlaces(ctx, 3, origin.Latitude, origin.Longitude, radius)
+		if err != nil {
+			t.Fatalf("radius %q: %v", radius, err)
+		}
+		if got == nil || total != want {
+			t.Errorf("radius %q: got %d places of %d, want %d in total", radius, len(got), total, want)
+		}
+		for i := range got {
+			if got[i].Distance > meters || (i > 0 && got[i].Distance < got[i-1].Distance) {
+				t.Errorf("radius %q: distances %v", radius, got[i].Distance)
+			}
+		}
+	}
+
+	if _, _, err := store.GetRecommendPlaces(ctx, 3, origin.Latitude, origin.Longitude, "5parsecs"); !errors.Is(err, db.ErrBadRequest) {
+		t.Errorf("unknown unit: got %v, want ErrBadRequest", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	// Все слова запроса в названии или адресе, регистр не важен
+	for query, want := range map[string][]int{
+		"Aviamotornaja": {6, 7, 8, 9},
+		"aviatorov dom": {10, 11, 12},
+		"kafe":          {2, 8, 17},
+		"Shkola":        {4, 9, 12, 19},
+	} {
+		got, total, err := store.Search(ctx, query, 20, 0)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if total != len(want) || !reflect.DeepEqual(sortedIDs(got), want) {
+			t.Errorf("%q: got %v of %d, want %v", query, sortedIDs(got), total, want)
+		}
+	}
+
+	// Допуск одной опечатки, как fuzziness AUTO в Elasticsearch
+	got, _, err := store.Search(ctx, "Brusnica", 10, 0)
+	if err != nil || fmt.Sprint(ids(got)) != "[5]" {
+		t.Errorf("typo: got %v, err %v", ids(got), err)
+	}
+
+	got, total, err := store.Search(ctx, "nothing like this", 10, 0)
+	if err != nil || got == nil || len(got) != 0 || total != 0 {
+		t.Errorf("no matches: %v of %d, err %v", got, total, err)
+	}
+}
+
+func TestGetPlacesInBoundingBox(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	topLeft := types.GeoJSON{Latitude: 55.76, Longitude: 37.70}
+	bottomRight := types.GeoJSON{Latitude: 55.74, Longitude: 37.73}
+	var want []int
+	for _, place := range fixture.Places() {
+		if geo.InBoundingBox(place.Location, topLeft, bottomRight) {
+			want = append(want, place.ID)
+		}
+	}
+
+	got, total, err := store.GetPlacesInBoundingBox(ctx, topLeft, bottomRight, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(want) || !reflect.DeepEqual(sortedIDs(got), want) {
+		t.Errorf("got %v of %d, want %v", sortedIDs(got), total, want)
+	}
+
+	// limit ограничивает места, но не total
+	got, total, err = store.GetPlacesInBoundingBox(ctx, topLeft, bottomRight, 1)
+	if err != nil || len(got) != 1 || total != len(want) {
+		t.Errorf("limit 1: %d places of %d, err %v", len(got), total, err)
+	}
+}
+
+func TestGetClusters(t *testing.T) {
+	store, fixture := newTestStore(t)
+	ctx := context.Background()
+
+	clusters, total, err := store.GetClusters(ctx, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for i, cluster := range clusters {
+		sum += cluster.Count
+		// Как в geohash_grid: сначала самые наполненные ячейки
+		if i > 0 && cluster.Count > clusters[i-1].Count {
+			t.Errorf("cluster %d has %d places, more than the previous %d", i, cluster.Count, clusters[i-1].Count)
+		}
+	}
+	if total != fixture.Total || sum != total {
+		t.Errorf("total %d, clusters hold %d places, want %d", total, sum, fixture.Total)
+	}
+
+	box := &types.BoundingBox{
+		TopLeft:     types.GeoJSON{Latitude: 55.76, Longitude: 37.70},
+		BottomRight: types.GeoJSON{Latitude: 55.74, Longitude: 37.73},
+	}
+	if _, total, err := store.GetClusters(ctx, 5, box); err != nil || total != 4 {
+		t.Errorf("bbox: total %d, err %v", total, err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.bleve")
+	if _, err := OpenReadOnly(missing); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("missing index: got %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReadOnly created %s", missing)
+	}
+
+	path := filepath.Join(t.TempDir(), "places.bleve")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Load([]*types.Place{{ID: 1, Name: "Rodnik", Location: origin}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	readOnly, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	if count, err := readOnly.Count(); err != nil || count != 1 {
+		t.Errorf("count %d, err %v", count, err)
+	}
+}
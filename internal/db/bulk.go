@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"elasticTask/pkg/types"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// PlaceIterator источник мест для индексации. Next возвращает io.EOF после последнего места.
+// *csvreader.Reader читает места из файла по одному.
+type PlaceIterator interface {
+	Next() (*types.Place, error)
+}
+
+// sliceIterator PlaceIterator поверх уже загруженных мест
+type sliceIterator struct {
+	places []*types.Place
+}
+
+// SlicePlaces возвращает PlaceIterator по срезу places
+func SlicePlaces(places []*types.Place) PlaceIterator {
+	return &sliceIterator{places: places}
+}
+
+func (s *sliceIterator) Next() (*types.Place, error) {
+	if len(s.places) == 0 {
+		return nil, io.EOF
+	}
+	place := s.places[0]
+	s.places = s.places[1:]
+	return place, nil
+}
+
+// BulkOptions параметры потоковой загрузки в BulkIndexer.
+// Поток не держит места в памяти, но память все же растет линейно с числом мест:
+// читатель файла помнит каждый ID для поиска дубликатов (Indexeres сверяет число документов
+// в новом индексе с числом отправленных мест и без этой проверки не переключил бы алиас),
+// а Sync держит ID и хеши всех мест индекса и ID уже прочитанных мест, чтобы найти удаленные.
+// Sync копит документы для отправки во временном файле, а не в памяти.
+type BulkOptions struct {
+	// Buffer емкость канала между чтением файла и BulkIndexer; чтение ждет, пока очередь не освободится
+	Buffer int
+	// ProgressEvery печатать прогресс каждые N проиндексированных мест; 0 - не печатать
+	ProgressEvery int
+	// MinDocuments Indexeres не переключает алиас на индекс, в который загружено меньше мест;
+	// значения меньше 1 считаются 1, чтобы пустой источник не подменил рабочий индекс пустым
+	MinDocuments int
+}
+
+// DefaultBulkOptions очередь на 1000 мест, прогресс каждые 1000 мест, хотя бы одно место в новом индексе
+func DefaultBulkOptions() BulkOptions {
+	return BulkOptions{Buffer: 1000, ProgressEvery: 1000, MinDocuments: 1}
+}
+
+// streamPlaces читает места из it в отдельной горутине и отдает их через канал емкостью buffer.
+// Канал закрывается в конце источника, при ошибке чтения или отмене ctx;
+// wait дожидается конца чтения и возвращает его ошибку (nil в конце источника); его можно вызывать повторно.
+func streamPlaces(ctx context.Context, it PlaceIterator, buffer int) (places <-chan *types.Place, wait func() error) {
+	ch := make(chan *types.Place, buffer)
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		for {
+			place, err := it.Next()
+			if errors.Is(err, io.EOF) {
+				done <- nil
+				return
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+
+			select {
+			case ch <- place:
+			case <-ctx.Done():
+				done <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	var (
+		once    sync.Once
+		readErr error
+	)
+	return ch, func() error {
+		once.Do(func() { readErr = <-done })
+		return readErr
+	}
+}
+
+// progress печатает количество обработанных мест каждые every мест
+type progress struct {
+	op    string
+	every uint64
+	start time.Time
+	count uint64
+}
+
+func newProgress(op string, every int) *progress {
+	return &progress{op: op, every: uint64(every), start: time.Now()}
+}
+
+// add учитывает одно место; безопасен для вызова из обработчиков BulkIndexer
+func (p *progress) add() {
+	n := atomic.AddUint64(&p.count, 1)
+	if p.every == 0 || n%p.every != 0 {
+		return
+	}
+
+	dur := time.Since(p.start)
+	log.Printf("%s: %s documents in %s (%s docs/sec)",
+		p.op,
+		humanize.Comma(int64(n)),
+		dur.Truncate(time.Millisecond),
+		humanize.Comma(int64(float64(n)/dur.Seconds())),
+	)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// ClientConfig параметры подключения к кластеру Elasticsearch
+type ClientConfig struct {
+	// Addresses адреса узлов кластера, например https://es1:9200
+	Addresses []string `json:"addresses" yaml:"addresses"`
+	// Username и Password для basic auth
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// APIKey ключ в кодировке base64; при заданном ключе basic auth не используется
+	APIKey string `json:"api_key" yaml:"api_key"`
+	// CACertFile путь к PEM-файлу сертификата центра, подписавшего сертификат кластера
+	CACertFile string `json:"ca_cert" yaml:"ca_cert"`
+	// CertificateFingerprint SHA256-отпечаток сертификата кластера в hex
+	CertificateFingerprint string `json:"ca_fingerprint" yaml:"ca_fingerprint"`
+	// RetryOnStatus коды ответа, при которых запрос повторяется
+	RetryOnStatus []int `json:"retry_on_status" yaml:"retry_on_status"`
+	// MaxRetries максимальное число повторов
+	MaxRetries int `json:"max_retries" yaml:"max_retries"`
+}
+
+// DefaultClientConfig локальный кластер без авторизации
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Addresses:     []string{"http://localhost:9200"},
+		RetryOnStatus: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		MaxRetries:    3,
+	}
+}
+
+// SplitList разбирает список через запятую, пропуская пустые элементы
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseStatusList разбирает коды HTTP-ответа через запятую
+func ParseStatusList(s string) ([]int, error) {
+	var statuses []int
+	for _, item := range SplitList(s) {
+		status, err := strconv.Atoi(item)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid HTTP status %q", item)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Validate проверяет согласованность параметров
+func (c ClientConfig) Validate() error {
+	if len(c.Addresses) == 0 {
+		return fmt.Errorf("no Elasticsearch addresses configured")
+	}
+	if c.APIKey != "" && (c.Username != "" || c.Password != "") {
+		return fmt.Errorf("both API key and basic auth credentials are set; use one of them")
+	}
+	if c.Password != "" && c.Username == "" {
+		return fmt.Errorf("password is set without a username")
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	return nil
+}
+
+// elasticsearchConfig переводит параметры в конфигурацию клиента, читая файл сертификата
+func (c ClientConfig) elasticsearchConfig() (elasticsearch.Config, error) {
+	if err := c.Validate(); err != nil {
+		return elasticsearch.Config{}, err
+	}
+
+	cfg := elasticsearch.Config{
+		Addresses:              c.Addresses,
+		Username:               c.Username,
+		Password:               c.Password,
+		APIKey:                 c.APIKey,
+		CertificateFingerprint: c.CertificateFingerprint,
+		RetryOnStatus:          c.RetryOnStatus,
+		MaxRetries:             c.MaxRetries,
+		DisableRetry:           c.MaxRetries == 0,
+	}
+
+	if c.CACertFile != "" {
+		cert, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return elasticsearch.Config{}, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		cfg.CACert = cert
+	}
+	return cfg, nil
+}
+
+// ClusterInfo сведения о кластере, к которому подключено хранилище
+type ClusterInfo struct {
+	Name     string `json:"cluster_name"`
+	UUID     string `json:"cluster_uuid"`
+	NodeName string `json:"name"`
+	Version  struct {
+		Number string `json:"number"`
+	} `json:"version"`
+}
+
+// Info Запрашивает сведения о кластере; используется для проверки адреса, авторизации и TLS при запуске
+func (es ElasticsearchStore) Info(ctx context.Context) (ClusterInfo, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	var info ClusterInfo
+	res, err := es.client.Info(es.client.Info.WithContext(ctx))
+	if err != nil {
+		return info, transportError("info", err)
+	}
+	if res.IsError() {
+		return info, responseError("info", res)
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("info: decoding response: %w", err)
+	}
+	return info, nil
+}
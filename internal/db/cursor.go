@@ -0,0 +1,211 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"elasticTask/pkg/types"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// pitKeepAlive время жизни point-in-time между запросами соседних страниц
+const pitKeepAlive = "1m"
+
+// ErrInvalidCursor курсор не удалось разобрать или его point-in-time уже истек.
+// Является ErrBadRequest: ошибка в параметрах клиента, а не в хранилище.
+var ErrInvalidCursor = fmt.Errorf("%w: invalid or expired cursor", ErrBadRequest)
+
+// pageCursor состояние постраничного обхода, которое клиент получает в виде непрозрачной строки
+type pageCursor struct {
+	PitID string        `json:"pit"`
+	After []interface{} `json:"after"`
+}
+
+func encodeCursor(c pageCursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.PitID == "" || len(c.After) == 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// openPointInTime открывает point-in-time на индексе и возвращает его идентификатор
+func (es ElasticsearchStore) openPointInTime(ctx context.Context) (string, error) {
+	res, err := es.client.OpenPointInTime(
+		[]string{es.indexName},
+		pitKeepAlive,
+		es.client.OpenPointInTime.WithContext(ctx),
+	)
+	if err != nil {
+		return "", transportError("open point in time", err)
+	}
+	if res.IsError() {
+		return "", responseError("open point in time", res)
+	}
+	defer res.Body.Close()
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// closePointInTime освобождает point-in-time на стороне Elasticsearch
+func (es ElasticsearchStore) closePointInTime(ctx context.Context, pitID string) error {
+	body, err := json.Marshal(map[string]string{"id": pitID})
+	if err != nil {
+		return err
+	}
+
+	res, err := es.client.ClosePointInTime(
+		es.client.ClosePointInTime.WithContext(ctx),
+		es.client.ClosePointInTime.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return transportError("close point in time", err)
+	}
+	// Истекший point-in-time уже закрыт на стороне Elasticsearch
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return responseError("close point in time", res)
+	}
+	res.Body.Close()
+	return nil
+}
+
+// GetPlacesAfter Находит следующую страницу мест по курсору (point-in-time + search_after по id).
+// В отличие от GetPlaces не ограничен max_result_window и не замедляется на дальних страницах.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// limit - количесвто мест
+// cursor - курсор предыдущей страницы; пустая строка - первая страница
+// Возвращает места, общее количество мест и курсор следующей страницы (пустой, если страниц больше нет).
+func (es ElasticsearchStore) GetPlacesAfter(ctx context.Context, limit int, cursor string) ([]types.Place, int, string, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	var state pageCursor
+	// pitOwned - point-in-time открыт этим вызовом и еще не отдан клиенту в курсоре
+	pitOwned := false
+	if cursor == "" {
+		pitID, err := es.openPointInTime(ctx)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		state.PitID = pitID
+
+		// Без курсора закрыть point-in-time больше некому: при ошибке он освобождается здесь,
+		// а не висит до истечения keep_alive. ctx к этому моменту может быть уже отменен.
+		pitOwned = true
+		defer func() {
+			if pitOwned {
+				es.closePointInTime(context.Background(), state.PitID)
+			}
+		}()
+	} else {
+		var err error
+		if state, err = decodeCursor(cursor); err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	query := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		},
+		"pit": map[string]interface{}{
+			"id":         state.PitID,
+			"keep_alive": pitKeepAlive,
+		},
+		"sort": []map[string]interface{}{
+			{"id": "asc"},
+		},
+	}
+	if len(state.After) > 0 {
+		query["search_after"] = state.After
+	}
+
+	// Запрос с point-in-time не указывает индекс: он уже зафиксирован в pit
+	res, err := es.doSearch(ctx, "search after", query,
+		es.client.Search.WithTrackTotalHits(true),
+	)
+	var storeErr *Error
+	if errors.As(err, &storeErr) && (storeErr.Status == http.StatusNotFound || storeErr.Type == "search_context_missing_exception") {
+		return nil, 0, "", fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer res.Body.Close()
+
+	result, err := decodeSearchResult(res)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	// Elasticsearch может вернуть обновленный идентификатор point-in-time
+	if result.PitID != "" {
+		state.PitID = result.PitID
+	}
+
+	places := result.Places()
+	if len(places) < limit {
+		pitOwned = false
+		return places, result.Total, "", es.closePointInTime(ctx, state.PitID)
+	}
+
+	state.After = result.Hits[len(result.Hits)-1].Sort
+	if len(state.After) == 0 {
+		return nil, 0, "", fmt.Errorf("search after: hit %q has no sort values", result.Hits[len(result.Hits)-1].ID)
+	}
+	next, err := encodeCursor(state)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	pitOwned = false
+	return places, result.Total, next, nil
+}
+
+// ScanPlaces Обходит все места индекса пачками по batchSize, не загружая их в память целиком.
+// fn вызывается для каждой пачки; ошибка fn прерывает обход и возвращается вызывающему.
+func (es ElasticsearchStore) ScanPlaces(ctx context.Context, batchSize int, fn func([]types.Place) error) error {
+	cursor := ""
+	for {
+		places, _, next, err := es.GetPlacesAfter(ctx, batchSize, cursor)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(places); err != nil {
+			if next != "" {
+				// ctx может быть уже отменен, а point-in-time все равно нужно освободить
+				if state, decodeErr := decodeCursor(next); decodeErr == nil {
+					es.closePointInTime(context.Background(), state.PitID)
+				}
+			}
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// pitServer открывает point-in-time "pit-1", отвечает на _search телом search со статусом status
+// и записывает идентификаторы закрытых point-in-time в closed
+func pitServer(t *testing.T, status int, search string, mu *sync.Mutex, closed *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/places/_pit":
+			io.WriteString(w, `{"id":"pit-1"}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			var body struct {
+				ID string `json:"id"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			*closed = append(*closed, body.ID)
+			mu.Unlock()
+			io.WriteString(w, `{"succeeded":true,"num_freed":1}`)
+		case r.URL.Path == "/_search":
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(status)
+			io.WriteString(w, search)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestGetPlacesAfterClosesPITOnError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		search string
+		want   error
+	}{
+		{"unavailable", 503, `{"error":"cluster is recovering"}`, ErrUnavailable},
+		{"bad response", 200, `{"hits":{"hits":[{"_id":"1","_source":{}}]}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				closed []string
+			)
+			store := newTestStore(t, 0, pitServer(t, tt.status, tt.search, &mu, &closed))
+
+			_, _, next, err := store.GetPlacesAfter(context.Background(), 10, "")
+			if err == nil || next != "" {
+				t.Fatalf("got cursor %q, err %v; want an error", next, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error %v, want %v", err, tt.want)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(closed) != 1 || closed[0] != "pit-1" {
+				t.Errorf("closed point-in-times %v, want [pit-1]", closed)
+			}
+		})
+	}
+}
+
+func TestGetPlacesAfterKeepsPITForCursor(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		closed []string
+	)
+	page := `{"pit_id":"pit-2","hits":{"total":{"value":3},"hits":[` +
+		`{"_id":"1","_source":{"id":1,"name":"A","location":{"lat":55.7,"lon":37.6}},"sort":[1]},` +
+		`{"_id":"2","_source":{"id":2,"name":"B","location":{"lat":55.7,"lon":37.6}},"sort":[2]}]}}`
+	store := newTestStore(t, 0, pitServer(t, 200, page, &mu, &closed))
+
+	_, _, next, err := store.GetPlacesAfter(context.Background(), 2, "")
+	if err != nil || next == "" {
+		t.Fatalf("got cursor %q, err %v", next, err)
+	}
+	// Курсор отдан клиенту: point-in-time закроется, когда обход дойдет до конца
+	mu.Lock()
+	defer mu.Unlock()
+	if len(closed) != 0 {
+		t.Errorf("closed %v while the cursor is still in use", closed)
+	}
+}
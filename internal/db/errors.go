@@ -0,0 +1,162 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Классы ошибок хранилища. Конкретная ошибка оборачивает один из них, проверять через errors.Is.
+var (
+	// ErrUnavailable Elasticsearch недоступен или перегружен (сеть, 429, 502, 503)
+	ErrUnavailable = errors.New("store unavailable")
+	// ErrBadRequest Elasticsearch отклонил запрос как некорректный (400)
+	ErrBadRequest = errors.New("bad request")
+	// ErrTimeout запрос не уложился во время (таймаут клиента, 408, 504)
+	ErrTimeout = errors.New("store timeout")
+	// ErrNotFound индекс или документ не найден (404)
+	ErrNotFound = errors.New("not found")
+)
+
+// errTimedOut Elasticsearch не успел опросить все шарды за отведенный timeout
+var errTimedOut = &Error{Op: "search", Kind: ErrTimeout, Reason: "search timed out on some shards"}
+
+// Error ошибка операции хранилища с классом Kind и подробностями ответа Elasticsearch
+type Error struct {
+	// Op название операции, например "search"
+	Op string
+	// Kind один из ErrUnavailable, ErrBadRequest, ErrTimeout, ErrNotFound; nil для прочих ошибок
+	Kind error
+	// Status HTTP-статус ответа Elasticsearch; 0, если ответа не было
+	Status int
+	// Type и Reason тип и описание ошибки из тела ответа Elasticsearch
+	Type   string
+	Reason string
+	// Err исходная ошибка транспорта
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Kind != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Kind.Error())
+	}
+	if e.Status != 0 {
+		fmt.Fprintf(&b, ": [%d]", e.Status)
+	}
+	if e.Type != "" {
+		b.WriteString(" ")
+		b.WriteString(e.Type)
+	}
+	if e.Reason != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Reason)
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// transportError классифицирует ошибку, при которой ответ от Elasticsearch не был получен
+func transportError(op string, err error) error {
+	e := &Error{Op: op, Kind: ErrUnavailable, Err: err}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.Kind = nil
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Kind = ErrTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		e.Kind = ErrTimeout
+	}
+	return e
+}
+
+// responseError классифицирует ответ Elasticsearch с ошибочным статусом и закрывает его тело
+func responseError(op string, res *esapi.Response) error {
+	defer res.Body.Close()
+
+	e := &Error{Op: op, Status: res.StatusCode}
+	switch res.StatusCode {
+	case http.StatusBadRequest:
+		e.Kind = ErrBadRequest
+	case http.StatusNotFound:
+		e.Kind = ErrNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		e.Kind = ErrTimeout
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		e.Kind = ErrUnavailable
+	}
+
+	// Тело ошибки Elasticsearch: {"error": {"type": ..., "reason": ...}, "status": ...}
+	// или {"error": "строка"} для некоторых API
+	raw, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && len(body.Error) > 0 {
+		var details struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(body.Error, &details) == nil {
+			e.Type, e.Reason = details.Type, details.Reason
+		} else {
+			json.Unmarshal(body.Error, &e.Reason)
+		}
+	} else {
+		e.Reason = strings.TrimSpace(string(raw))
+	}
+	return e
+}
+
+// doSearch выполняет _search с телом body в контексте ctx. При успехе тело ответа остается открытым и
+// закрывается вызывающим, при ошибке возвращается классифицированная *Error.
+func (es ElasticsearchStore) doSearch(ctx context.Context, op string, body interface{}, options ...func(*esapi.SearchRequest)) (*esapi.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append([]func(*esapi.SearchRequest){
+		es.client.Search.WithContext(ctx),
+		es.client.Search.WithBody(bytes.NewReader(raw)),
+	}, options...)
+	// Elasticsearch прекращает поиск на шардах по истечении timeout и помечает ответ timed_out
+	if es.queryTimeout > 0 {
+		options = append(options, es.client.Search.WithTimeout(es.queryTimeout))
+	}
+
+	res, err := es.client.Search(options...)
+	if err != nil {
+		return nil, transportError(op, err)
+	}
+	if res.IsError() {
+		return nil, responseError(op, res)
+	}
+	return res, nil
+}
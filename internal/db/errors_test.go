@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// errorServer отвечает статусом status и телом ошибки Elasticsearch
+func errorServer(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func TestStoreErrorClasses(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		timeout    time.Duration
+		want       error
+		wantStatus int
+		wantType   string
+	}{
+		{
+			name:       "bad request",
+			handler:    errorServer(400, `{"error":{"type":"parse_exception","reason":"failed to parse query"},"status":400}`),
+			want:       ErrBadRequest,
+			wantStatus: 400,
+			wantType:   "parse_exception",
+		},
+		{
+			name:       "index missing",
+			handler:    errorServer(404, `{"error":{"type":"index_not_found_exception","reason":"no such index [places]"},"status":404}`),
+			want:       ErrNotFound,
+			wantStatus: 404,
+			wantType:   "index_not_found_exception",
+		},
+		{
+			name:       "too many requests",
+			handler:    errorServer(429, `{"error":{"type":"es_rejected_execution_exception","reason":"rejected execution"},"status":429}`),
+			want:       ErrUnavailable,
+			wantStatus: 429,
+			wantType:   "es_rejected_execution_exception",
+		},
+		{
+			name:       "unavailable",
+			handler:    errorServer(503, `{"error":"cluster is recovering"}`),
+			want:       ErrUnavailable,
+			wantStatus: 503,
+		},
+		{
+			name:       "gateway timeout",
+			handler:    errorServer(504, `upstream timed out`),
+			want:       ErrTimeout,
+			wantStatus: 504,
+		},
+		{
+			name:    "shards timed out",
+			handler: errorServer(200, `{"timed_out":true,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`),
+			want:    ErrTimeout,
+		},
+		{
+			name: "slow response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				// Сервер замечает разрыв соединения клиентом только после чтения тела запроса
+				io.Copy(io.Discard, r.Body)
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+			timeout: 50 * time.Millisecond,
+			want:    ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.timeout, tt.handler)
+
+			_, _, err := store.GetPlaces(context.Background(), 10, 0, SortOption{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("GetPlaces error %v, want %v", err, tt.want)
+			}
+			for _, other := range []error{ErrUnavailable, ErrBadRequest, ErrTimeout, ErrNotFound} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("error %v also matches %v", err, other)
+				}
+			}
+
+			var storeErr *Error
+			if !errors.As(err, &storeErr) {
+				t.Fatalf("error %T is not *Error", err)
+			}
+			if storeErr.Status != tt.wantStatus || storeErr.Type != tt.wantType {
+				t.Errorf("got status %d type %q, want %d %q", storeErr.Status, storeErr.Type, tt.wantStatus, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestStoreErrorReason(t *testing.T) {
+	store := newTestStore(t, 0, errorServer(503, `{"error":"cluster is recovering"}`))
+
+	_, err := store.GetPlace(context.Background(), 1)
+	var storeErr *Error
+	if !errors.As(err, &storeErr) {
+		t.Fatalf("error %T is not *Error", err)
+	}
+	if storeErr.Op != "get place" || storeErr.Reason != "cluster is recovering" {
+		t.Errorf("got op %q reason %q", storeErr.Op, storeErr.Reason)
+	}
+}
+
+func TestStoreTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	store, err := NewElasticsearchStore(ClientConfig{Addresses: []string{server.URL}}, "places", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.GetPlaces(context.Background(), 10, 0, SortOption{}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("connection refused: got %v, want %v", err, ErrUnavailable)
+	}
+
+	// Отмена запроса клиентом не относится ни к одному классу
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = store.GetPlaces(ctx, 10, 0, SortOption{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled: got %v, want context.Canceled", err)
+	}
+	for _, class := range []error{ErrUnavailable, ErrBadRequest, ErrTimeout, ErrNotFound} {
+		if errors.Is(err, class) {
+			t.Errorf("canceled request classified as %v", class)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package fakestore реализует интерфейсы хранилища db поверх записанного ответа Elasticsearch.
+// Предназначен для тестов обработчиков web без запущенного Elasticsearch.
+package fakestore
+
+import (
+	"bytes"
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// searchResponse записанный ответ _search индекса places (первые 20 мест из data/data.csv)
+//
+//go:embed testdata/search_response.json
+var searchResponse []byte
+
+// Store фейковое хранилище. Места берутся из записанного ответа Elasticsearch,
+// запросы выполняются простым перебором в памяти.
+type Store struct {
+	// Places места, по которым отвечает хранилище
+	Places []types.Place
+	// Err, если задана, возвращается всеми методами вместо результата
+	Err error
+	// Tile ответ GetTile
+	Tile []byte
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var (
+	_ db.Store        = (*Store)(nil)
+	_ db.GeoStore     = (*Store)(nil)
+	_ db.TileStore    = (*Store)(nil)
+	_ db.PlaceScanner = (*Store)(nil)
+)
+
+// New создает хранилище по встроенному записанному ответу
+func New() (*Store, error) {
+	return NewFromFixture(bytes.NewReader(searchResponse))
+}
+
+// NewFromFixture создает хранилище по записанному ответу _search из r
+func NewFromFixture(r io.Reader) (*Store, error) {
+	result, err := db.DecodeSearchResponse(r)
+	if err != nil {
+		return nil, fmt.Errorf("load fixture: %w", err)
+	}
+	return &Store{Places: result.Places()}, nil
+}
+
+// Calls возвращает имена вызванных методов в порядке вызова
+func (s *Store) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *Store) record(name string) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, name)
+	s.mu.Unlock()
+	return s.Err
+}
+
+// page возвращает срез places[offset:offset+limit] с проверкой границ
+func page(places []types.Place, limit, offset int) []types.Place {
+	if offset >= len(places) {
+		return []types.Place{}
+	}
+	end := offset + limit
+	if end > len(places) {
+		end = len(places)
+	}
+	return places[offset:end]
+}
+
+func (s *Store) GetPlaces(ctx context.Context, limit int, offset int, order db.SortOption) ([]types.Place, int, error) {
+	if err := s.record("GetPlaces"); err != nil {
+		return nil, 0, err
+	}
+
+	places := append([]types.Place(nil), s.Places...)
+	switch order.Field {
+	case db.SortByName:
+		sort.SliceStable(places, func(i, j int) bool { return (places[i].Name < places[j].Name) != order.Desc })
+	case db.SortByID:
+		sort.SliceStable(places, func(i, j int) bool { return (places[i].ID < places[j].ID) != order.Desc })
+	case db.SortByDistance:
+		if order.Origin != nil {
+			origin := *order.Origin
+			sort.SliceStable(places, func(i, j int) bool {
+				return (geo.Distance(origin, places[i].Location) < geo.Distance(origin, places[j].Location)) != order.Desc
+			})
+		}
+	}
+	return page(places, limit, offset), len(places), nil
+}
+
+func (s *Store) GetPlacesAfter(ctx context.Context, limit int, cursor string) ([]types.Place, int, string, error) {
+	if err := s.record("GetPlacesAfter"); err != nil {
+		return nil, 0, "", err
+	}
+
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			return nil, 0, "", db.ErrInvalidCursor
+		}
+	}
+
+	places := page(s.Places, limit, offset)
+	next := ""
+	if offset+len(places) < len(s.Places) {
+		next = strconv.Itoa(offset + len(places))
+	}
+	return places, len(s.Places), next, nil
+}
+
+func (s *Store) GetPlace(ctx context.Context, id int) (types.Place, error) {
+	if err := s.record("GetPlace"); err != nil {
+		return types.Place{}, err
+	}
+
+	for _, place := range s.Places {
+		if place.ID == id {
+			return place, nil
+		}
+	}
+	return types.Place{}, fmt.Errorf("place %d: %w", id, db.ErrNotFound)
+}
+
+func (s *Store) GetRecommendPlaces(ctx context.Context, limit int, lat, lon float64, radius string) ([]types.RecommendedPlace, int, error) {
+	if err := s.record("GetRecommendPlaces"); err != nil {
+		return nil, 0, err
+	}
+
+	maxDistance := -1.0
+	if radius != "" {
+		var err error
+		if maxDistance, err = geo.ParseDistance(radius); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s", db.ErrBadRequest, err)
+		}
+	}
+
+	origin := types.GeoJSON{Latitude: lat, Longitude: lon}
+	var found []types.RecommendedPlace
+	for _, place := range s.Places {
+		distance := geo.Distance(origin, place.Location)
+		if maxDistance >= 0 && distance > maxDistance {
+			continue
+		}
+		found = append(found, types.RecommendedPlace{Place: place, Distance: distance})
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Distance < found[j].Distance })
+
+	total := len(found)
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, total, nil
+}
+
+// matches проверяет, что все слова запроса встречаются в названии или адресе места
+func matches(place types.Place, query string) bool {
+	text := strings.ToLower(place.Name + " " + place.Address)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) Search(ctx context.Context, query string, limit int, offset int) ([]types.Place, int, error) {
+	if err := s.record("Search"); err != nil {
+		return nil, 0, err
+	}
+
+	var found []types.Place
+	for _, place := range s.Places {
+		if matches(place, query) {
+			found = append(found, place)
+		}
+	}
+	return page(found, limit, offset), len(found), nil
+}
+
+func (s *Store) Suggest(ctx context.Context, prefix string, limit int, origin *types.GeoJSON) ([]types.Suggestion, error) {
+	if err := s.record("Suggest"); err != nil {
+		return nil, err
+	}
+
+	suggestions := []types.Suggestion{}
+	for _, place := range s.Places {
+		if len(suggestions) == limit {
+			break
+		}
+		if matches(place, prefix) {
+			suggestions = append(suggestions, types.Suggestion{
+				ID:       place.ID,
+				Name:     place.Name,
+				Address:  place.Address,
+				Location: place.Location,
+			})
+		}
+	}
+	return suggestions, nil
+}
+
+func (s *Store) GetPlacesInBoundingBox(ctx context.Context, topLeft, bottomRight types.GeoJSON, limit int) ([]types.Place, int, error) {
+	if err := s.record("GetPlacesInBoundingBox"); err != nil {
+		return nil, 0, err
+	}
+
+	var found []types.Place
+	for _, place := range s.Places {
+		if geo.InBoundingBox(place.Location, topLeft, bottomRight) {
+			found = append(found, place)
+		}
+	}
+	return page(found, limit, 0), len(found), nil
+}
+
+// GetClusters возвращает по одному кластеру на место: фейку не нужна настоящая geohash-сетка
+func (s *Store) GetClusters(ctx context.Context, precision int, box *types.BoundingBox) ([]types.Cluster, int, error) {
+	if err := s.record("GetClusters"); err != nil {
+		return nil, 0, err
+	}
+
+	clusters := []types.Cluster{}
+	for _, place := range s.Places {
+		if box != nil && !geo.InBoundingBox(place.Location, box.TopLeft, box.BottomRight) {
+			continue
+		}
+		clusters = append(clusters, types.Cluster{
+			Geohash:  strconv.Itoa(place.ID),
+			Count:    1,
+			Centroid: place.Location,
+		})
+	}
+	return clusters, len(clusters), nil
+}
+
+func (s *Store) GetTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := s.record("GetTile"); err != nil {
+		return nil, err
+	}
+	return s.Tile, nil
+}
+
+func (s *Store) ScanPlaces(ctx context.Context, batchSize int, fn func([]types.Place) error) error {
+	if err := s.record("ScanPlaces"); err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(s.Places); offset += batchSize {
+		if err := fn(page(s.Places, batchSize, offset)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"elasticTask/pkg/types"
+	"encoding/json"
+)
+
+// GetPlacesInBoundingBox Находит места внутри прямоугольника видимой области карты.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// topLeft - северо-западный угол прямоугольника
+// bottomRight - юго-восточный угол прямоугольника
+// limit - максимальное количество мест в ответе; общее количество мест в прямоугольнике возвращается отдельно
+func (es ElasticsearchStore) GetPlacesInBoundingBox(ctx context.Context, topLeft, bottomRight types.GeoJSON, limit int) ([]types.Place, int, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	query := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"geo_bounding_box": map[string]interface{}{
+						"location": map[string]interface{}{
+							"top_left": map[string]interface{}{
+								"lat": topLeft.Latitude,
+								"lon": topLeft.Longitude,
+							},
+							"bottom_right": map[string]interface{}{
+								"lat": bottomRight.Latitude,
+								"lon": bottomRight.Longitude,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	res, err := es.doSearch(ctx, "bounding box search", query,
+		es.client.Search.WithIndex(es.indexName),
+		es.client.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	return decodePlaces(res)
+}
+
+// maxClusterBuckets ограничивает количество ячеек в ответе агрегации. Сверх него geohash_grid
+// отбрасывает наименее наполненные ячейки, и места в них остаются только в total.
+const maxClusterBuckets = 10000
+
+// GetClusters Группирует места в ячейки geohash-сетки с центром масс каждой ячейки.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// precision - точность geohash от 1 (материк) до 12 (сантиметры)
+// box - необязательный прямоугольник карты; nil - весь индекс
+// Возвращает ячейки и общее количество мест, попавших в агрегацию; при точности, дающей больше
+// maxClusterBuckets ячеек, сумма мест в возвращенных ячейках меньше total.
+func (es ElasticsearchStore) GetClusters(ctx context.Context, precision int, box *types.BoundingBox) ([]types.Cluster, int, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	grid := map[string]interface{}{
+		"field":     "location",
+		"precision": precision,
+		"size":      maxClusterBuckets,
+	}
+
+	filter := []map[string]interface{}{}
+	if box != nil {
+		bounds := map[string]interface{}{
+			"top_left": map[string]interface{}{
+				"lat": box.TopLeft.Latitude,
+				"lon": box.TopLeft.Longitude,
+			},
+			"bottom_right": map[string]interface{}{
+				"lat": box.BottomRight.Latitude,
+				"lon": box.BottomRight.Longitude,
+			},
+		}
+		grid["bounds"] = bounds
+		filter = append(filter, map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{
+				"location": bounds,
+			},
+		})
+	}
+
+	query := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filter,
+			},
+		},
+		"aggs": map[string]interface{}{
+			"clusters": map[string]interface{}{
+				"geohash_grid": grid,
+				"aggs": map[string]interface{}{
+					"centroid": map[string]interface{}{
+						"geo_centroid": map[string]interface{}{
+							"field": "location",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	res, err := es.doSearch(ctx, "cluster aggregation", query,
+		es.client.Search.WithIndex(es.indexName),
+		es.client.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	var response struct {
+		TimedOut bool `json:"timed_out"`
+		Hits     struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Clusters struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int    `json:"doc_count"`
+					Centroid struct {
+						Location types.GeoJSON `json:"location"`
+					} `json:"centroid"`
+				} `json:"buckets"`
+			} `json:"clusters"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, 0, err
+	}
+
+	if response.TimedOut {
+		return nil, 0, errTimedOut
+	}
+
+	clusters := make([]types.Cluster, 0, len(response.Aggregations.Clusters.Buckets))
+	for _, bucket := range response.Aggregations.Clusters.Buckets {
+		clusters = append(clusters, types.Cluster{
+			Geohash:  bucket.Key,
+			Count:    bucket.DocCount,
+			Centroid: bucket.Centroid.Location,
+		})
+	}
+	return clusters, response.Hits.Total.Value, nil
+}
@@ -1,19 +1,24 @@
 package db
 
 import (
-	"bytes"
 	"context"
 	"elasticTask/pkg/types"
 	"encoding/json"
-	"log"
+	"fmt"
+	"strconv"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
 // GetPlaces Находит места из Elasticsearch в нужном количестве для отображения в html
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
 // limit - количесвто мест
 // offset - смещение (начальная позиция) для запроса к Elasticsearch
-func (es ElasticsearchStore) GetPlaces(limit int, offset int) ([]types.Place, int, error) {
+// sort - порядок мест; нулевое значение оставляет порядок индекса
+func (es ElasticsearchStore) GetPlaces(ctx context.Context, limit int, offset int, sort SortOption) ([]types.Place, int, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
 	query := map[string]interface{}{
 		"from": offset,
 		"size": limit,
@@ -21,123 +26,226 @@ func (es ElasticsearchStore) GetPlaces(limit int, offset int) ([]types.Place, in
 			"match_all": map[string]interface{}{},
 		},
 	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, 0, err
+	if clause := sort.clause(); clause != nil {
+		query["sort"] = []interface{}{clause}
 	}
 
-	res, err := es.client.Search(
-		es.client.Search.WithContext(context.Background()),
+	res, err := es.doSearch(ctx, "list places", query,
 		es.client.Search.WithIndex(es.indexName),
-		es.client.Search.WithBody(&buf),
 		es.client.Search.WithTrackTotalHits(true),
-		es.client.Search.WithPretty(),
 	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	return decodePlaces(res)
+}
 
+// GetPlace Находит место по идентификатору.
+// ctx - контекст запроса
+// id - идентификатор места
+// Возвращает ошибку ErrNotFound, если места с таким идентификатором нет.
+func (es ElasticsearchStore) GetPlace(ctx context.Context, id int) (types.Place, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := es.client.Get(es.indexName, strconv.Itoa(id), es.client.Get.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Elasticsearch Search() API ERROR: %v", err)
+		return types.Place{}, transportError("get place", err)
 	}
 	if res.IsError() {
-		log.Fatalf("Elasticsearch Search() API ERROR: %v", res)
+		return types.Place{}, responseError("get place", res)
 	}
-
 	defer res.Body.Close()
-	places, totalValue, err := ConvertResultsToPlaces(res)
-	return places, totalValue, nil
+
+	var response struct {
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return types.Place{}, fmt.Errorf("decode place %d: %w", id, err)
+	}
+
+	place, err := decodePlace(response.Source)
+	if err != nil {
+		return types.Place{}, fmt.Errorf("decode place %d: %w", id, err)
+	}
+	return place, nil
 }
 
+// recommendPrefilterRadius ограничивает поиск ближайших мест, если радиус не задан пользователем.
+// Elasticsearch сортирует только документы внутри круга, а не весь индекс.
+const recommendPrefilterRadius = "5km"
+
 // GetRecommendPlaces Находит самые близкие рекомендованные места по долшоте и широте.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
 // limit - количесвто мест
 // lat - широта
 // lon - долгота
-func (es ElasticsearchStore) GetRecommendPlaces(limit int, lat, lon float64) ([]types.Place, int, error) {
+// radius - необязательный радиус поиска с единицами измерения ("500m", "2km"); пустая строка - без ограничения
+//
+// Без радиуса сначала ищем внутри recommendPrefilterRadius и только если там меньше limit мест,
+// повторяем запрос по всему индексу. total без радиуса - количество мест во всем индексе,
+// как в других хранилищах; с радиусом - количество мест внутри него.
+func (es ElasticsearchStore) GetRecommendPlaces(ctx context.Context, limit int, lat, lon float64, radius string) ([]types.RecommendedPlace, int, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	if radius != "" {
+		return es.searchClosest(ctx, limit, lat, lon, radius, false)
+	}
+
+	places, total, err := es.searchClosest(ctx, limit, lat, lon, recommendPrefilterRadius, true)
+	if err != nil || len(places) >= limit {
+		return places, total, err
+	}
+	return es.searchClosest(ctx, limit, lat, lon, "", false)
+}
+
+// searchClosest выполняет запрос ближайших мест с нативной сортировкой geo_distance.
+// radius - радиус фильтра geo_distance; пустая строка - без фильтра.
+// countIndex - вернуть вместо числа мест внутри radius число мест во всем индексе:
+// его считает агрегация global в том же запросе.
+func (es ElasticsearchStore) searchClosest(ctx context.Context, limit int, lat, lon float64, radius string, countIndex bool) ([]types.RecommendedPlace, int, error) {
+	origin := map[string]interface{}{
+		"lat": lat,
+		"lon": lon,
+	}
+
+	filter := []map[string]interface{}{}
+	if radius != "" {
+		filter = append(filter, map[string]interface{}{
+			"geo_distance": map[string]interface{}{
+				"distance": radius,
+				"location": origin,
+			},
+		})
+	}
+
 	query := map[string]interface{}{
 		"size": limit,
 		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
+			"bool": map[string]interface{}{
+				"filter": filter,
+			},
 		},
 		"sort": []map[string]interface{}{
 			{
-				"_script": map[string]interface{}{
-					"type": "number",
-					"script": map[string]interface{}{
-						"lang":   "painless",
-						"source": "doc['location'].arcDistance(params.lat, params.lon)",
-						"params": map[string]interface{}{
-							"lat": lat,
-							"lon": lon,
-						},
-					},
-					"order": "asc",
+				"_geo_distance": map[string]interface{}{
+					"location":      origin,
+					"order":         "asc",
+					"unit":          "m",
+					"distance_type": "arc",
 				},
 			},
 		},
 	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, 0, err
+	if countIndex {
+		query["aggs"] = map[string]interface{}{
+			"indexed": map[string]interface{}{
+				"global": map[string]interface{}{},
+			},
+		}
 	}
 
-	res, err := es.client.Search(
-		es.client.Search.WithContext(context.Background()),
+	res, err := es.doSearch(ctx, "recommend places", query,
 		es.client.Search.WithIndex(es.indexName),
-		es.client.Search.WithBody(&buf),
-		es.client.Search.WithTrackTotalHits(true),
-		es.client.Search.WithPretty(),
+		es.client.Search.WithTrackTotalHits(!countIndex),
 	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
 
+	result, err := decodeSearchResult(res)
 	if err != nil {
-		log.Fatalf("Elasticsearch Search() API ERROR: %v", err)
+		return nil, 0, err
 	}
-	if res.IsError() {
-		log.Fatalf("Elasticsearch Search() API ERROR: %v", res)
+	places, err := convertResultsToRecommendations(result)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !countIndex {
+		return places, result.Total, nil
 	}
 
-	defer res.Body.Close()
-
-	places, totalValue, err := ConvertResultsToPlaces(res)
-	return places, totalValue, nil
+	var indexed struct {
+		DocCount *int `json:"doc_count"`
+	}
+	if err := json.Unmarshal(result.Aggregations["indexed"], &indexed); err != nil || indexed.DocCount == nil {
+		return nil, 0, fmt.Errorf("recommend places: response has no %q aggregation", "indexed")
+	}
+	return places, *indexed.DocCount, nil
 }
 
-// ConvertResultsToPlaces преобразует ответ Elasticsearch в слайс мест и общее количество найденных мест.
-// res - ответ Elasticsearch
-func ConvertResultsToPlaces(res *esapi.Response) ([]types.Place, int, error) {
+// decodeSearchResult разбирает ответ поиска Elasticsearch в SearchResult.
+// Ответ с timed_out считается ошибкой ErrTimeout: результаты в нем неполные.
+func decodeSearchResult(res *esapi.Response) (*SearchResult, error) {
+	result, err := DecodeSearchResponse(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if result.TimedOut {
+		return nil, errTimedOut
+	}
+	return result, nil
+}
 
-	var response map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+// decodePlaces разбирает ответ поиска Elasticsearch в слайс мест и общее количество найденных мест
+func decodePlaces(res *esapi.Response) ([]types.Place, int, error) {
+	result, err := decodeSearchResult(res)
+	if err != nil {
 		return nil, 0, err
 	}
+	return result.Places(), result.Total, nil
+}
 
-	// Извлечение массива "hits" из секции "hits" в JSON-ответе Elasticsearch
-	hits := response["hits"].(map[string]interface{})["hits"].([]interface{})
-
-	totalValue := int(response["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
-
-	// Слайс для хранения мест
-	var places []types.Place
-	// Счетчик найденных мест
-	count := 0
-
-	for _, hit := range hits {
-		count++
-		// Преобразование интерфейса в map[string]interface{}
-		hitMap := hit.(map[string]interface{})
-		// Извлечение "_source" из текущего "hit"
-		source := hitMap["_source"].(map[string]interface{})
-		// Создание объекта типа Place на основе данных из "_source"
-		place := types.Place{
-			ID:      int(source["id"].(float64)),
-			Name:    source["name"].(string),
-			Address: source["address"].(string),
-			Phone:   source["phone"].(string),
-			Location: types.GeoJSON{
-				Latitude:  source["location"].(map[string]interface{})["lat"].(float64),
-				Longitude: source["location"].(map[string]interface{})["lon"].(float64),
-			},
+// convertResultsToRecommendations преобразует ответ Elasticsearch с сортировкой geo_distance
+// в слайс рекомендованных мест. Расстояние в метрах берется из первого значения сортировки.
+func convertResultsToRecommendations(result *SearchResult) ([]types.RecommendedPlace, error) {
+	places := make([]types.RecommendedPlace, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		distance, err := hit.sortValueFloat(0)
+		if err != nil {
+			return nil, fmt.Errorf("recommendation distance: %w", err)
 		}
-		places = append(places, place)
+		places = append(places, types.RecommendedPlace{
+			Place:    hit.Place,
+			Distance: distance,
+		})
+	}
+	return places, nil
+}
+
+// Search Выполняет полнотекстовый поиск мест по названию и адресу с учетом опечаток.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// query - строка поиска
+// limit - количесвто мест
+// offset - смещение (начальная позиция) для запроса к Elasticsearch
+func (es ElasticsearchStore) Search(ctx context.Context, query string, limit int, offset int) ([]types.Place, int, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	body := map[string]interface{}{
+		"from": offset,
+		"size": limit,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{"name^2", "address"},
+				"fuzziness": "AUTO",
+				"operator":  "and",
+			},
+		},
 	}
-	return places, totalValue, nil
+
+	res, err := es.doSearch(ctx, "search places", body,
+		es.client.Search.WithIndex(es.indexName),
+		es.client.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	return decodePlaces(res)
 }
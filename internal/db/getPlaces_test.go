@@ -0,0 +1,142 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestStore создает хранилище поверх httptest-сервера с обработчиком handler.
+// Сервер закрывается по окончании теста.
+func newTestStore(tb testing.TB, queryTimeout time.Duration, handler http.HandlerFunc) *ElasticsearchStore {
+	tb.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Клиент v8 отказывается работать с сервером без этого заголовка
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		handler(w, r)
+	}))
+	tb.Cleanup(server.Close)
+
+	store, err := NewElasticsearchStore(ClientConfig{Addresses: []string{server.URL}}, "places", queryTimeout)
+	if err != nil {
+		tb.Fatalf("NewElasticsearchStore: %v", err)
+	}
+	return store
+}
+
+// geoDistanceServer отвечает записанным ответом с сортировкой _geo_distance.
+// Если prefilterEmpty, запросы с фильтром geo_distance ничего не находят.
+func geoDistanceServer(tb testing.TB, prefilterEmpty bool, requests *int64) http.HandlerFunc {
+	tb.Helper()
+
+	response, err := os.ReadFile("testdata/geo_distance_response.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if prefilterEmpty && bytes.Contains(body, []byte(`"geo_distance":{"distance"`)) {
+			// Агрегация global считает весь индекс, даже если фильтр ничего не нашел
+			io.WriteString(w, `{"took":1,"timed_out":false,"hits":{"hits":[]},"aggregations":{"indexed":{"doc_count":20}}}`)
+			return
+		}
+		w.Write(response)
+	}
+}
+
+func TestGetRecommendPlaces(t *testing.T) {
+	var requests int64
+	store := newTestStore(t, 0, geoDistanceServer(t, false, &requests))
+
+	places, total, err := store.GetRecommendPlaces(context.Background(), 3, 55.7522, 37.6156, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Без радиуса total - все места индекса из агрегации global, а не только найденные внутри фильтра
+	if total != 20 || len(places) != 3 {
+		t.Fatalf("got %d places of %d, want 3 of 20", len(places), total)
+	}
+	if requests != 1 {
+		t.Errorf("prefilter with enough places made %d requests, want 1", requests)
+	}
+
+	// Расстояние берется из значения сортировки _geo_distance
+	wantIDs := []int{15, 5, 2}
+	for i, place := range places {
+		if place.ID != wantIDs[i] {
+			t.Errorf("place %d: id %d, want %d", i, place.ID, wantIDs[i])
+		}
+		if place.Distance <= 0 || (i > 0 && place.Distance < places[i-1].Distance) {
+			t.Errorf("place %d: distance %v is not ascending", i, place.Distance)
+		}
+	}
+}
+
+func TestGetRecommendPlacesRadius(t *testing.T) {
+	var requests int64
+	store := newTestStore(t, 0, geoDistanceServer(t, false, &requests))
+
+	// С радиусом total - количество мест внутри него
+	_, total, err := store.GetRecommendPlaces(context.Background(), 3, 55.7522, 37.6156, "5km")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || requests != 1 {
+		t.Errorf("total %d in %d requests, want 4 in 1", total, requests)
+	}
+}
+
+func TestGetRecommendPlacesFallback(t *testing.T) {
+	var requests int64
+	store := newTestStore(t, 0, geoDistanceServer(t, true, &requests))
+
+	places, total, err := store.GetRecommendPlaces(context.Background(), 3, 55.7522, 37.6156, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 3 || requests != 2 {
+		t.Fatalf("got %d places in %d requests, want 3 in 2", len(places), requests)
+	}
+	// Запрос по всему индексу считает все места в hits.total
+	if total != 4 {
+		t.Errorf("total %d, want hits.total 4", total)
+	}
+}
+
+// BenchmarkGetRecommendPlaces измеряет клиентскую часть запроса рекомендаций: построение запроса,
+// обмен с сервером и разбор ответа с расстояниями из сортировки _geo_distance.
+// prefilter - места нашлись внутри recommendPrefilterRadius; fallback - пришлось искать по всему индексу.
+func BenchmarkGetRecommendPlaces(b *testing.B) {
+	for _, bench := range []struct {
+		name           string
+		prefilterEmpty bool
+	}{
+		{"prefilter", false},
+		{"fallback", true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var requests int64
+			store := newTestStore(b, 0, geoDistanceServer(b, bench.prefilterEmpty, &requests))
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := store.GetRecommendPlaces(ctx, 3, 55.7522, 37.6156, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&requests))/float64(b.N), "requests/op")
+		})
+	}
+}
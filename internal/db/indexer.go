@@ -3,15 +3,12 @@ package db
 import (
 	"bytes"
 	"context"
-	"elasticTask/internal/csvreader"
-	"elasticTask/pkg/types"
 	"encoding/json"
 	"fmt"
 	"log"
 	"runtime"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -19,61 +16,108 @@ import (
 	"github.com/elastic/go-elasticsearch/v8/esutil"
 )
 
-func (es ElasticsearchStore) Indexeres(indexName string) {
+// Indexeres Потоково загружает места из places в новый индекс <alias>_v<время> и,
+// если количество документов сошлось и не меньше opts.MinDocuments, атомарно переключает на него алиас alias.
+// ID мест в places должны быть уникальны: повтор оставит в индексе меньше документов, чем отправлено,
+// и алиас не переключится. Читатели файлов отклоняют повторы сами.
+// Индекс, на который алиас указывал раньше, остается для отката; более старые версии удаляются.
+// Возвращает классифицированную ошибку хранилища, если Elasticsearch отклонил запрос.
+// opts задает размер очереди между чтением и BulkIndexer и частоту сообщений о прогрессе.
+func (es ElasticsearchStore) Indexeres(ctx context.Context, alias string, places PlaceIterator, opts BulkOptions) error {
 	log.SetFlags(0)
 
 	var (
-		numWorkers      = runtime.NumCPU()
-		flushBytes      = 5e+6
-		data            []*types.Place
-		countSuccessful uint64
-		res             *esapi.Response
-		err             error
+		numWorkers = runtime.NumCPU()
+		flushBytes = 5e+6
+		err        error
 	)
 
 	log.Println(strings.Repeat("▁", 65))
-	data = csvreader.CsvReader()
 
-	// check index exsist
-	existsReq := esapi.IndicesExistsRequest{
-		Index: []string{indexName},
+	indexName := versionedIndexName(alias, time.Now())
+	if err := es.createIndex(ctx, indexName); err != nil {
+		return err
 	}
+	log.Printf("Index '%s' created successfully", indexName)
 
-	existsRes, err := existsReq.Do(context.Background(), es.client)
+	// Пока алиас не переключен, новый индекс никому не виден: при ошибке его можно просто удалить
+	sent, err := es.loadIndex(ctx, indexName, places, opts, numWorkers, int(flushBytes))
 	if err != nil {
-		log.Fatalf("Error checking index existence: %s", err)
+		es.dropFailedIndex(indexName)
+		return err
 	}
-	defer existsRes.Body.Close()
 
-	if existsRes.StatusCode == 200 {
-		deleteReq := esapi.IndicesDeleteRequest{
-			Index: []string{indexName},
-		}
+	// Пустой источник (только заголовок, все строки отклонены) не должен подменить рабочий индекс пустым
+	minDocuments := opts.MinDocuments
+	if minDocuments < 1 {
+		minDocuments = 1
+	}
+	if sent < minDocuments {
+		es.dropFailedIndex(indexName)
+		return fmt.Errorf("loaded %d places, expected at least %d; alias %s left unchanged", sent, minDocuments, alias)
+	}
 
-		deleteRes, err := deleteReq.Do(context.Background(), es.client)
-		if err != nil {
-			log.Fatalf("Error deleting index: %s", err)
-		}
-		defer deleteRes.Body.Close()
+	if err := es.refreshIndex(ctx, indexName); err != nil {
+		es.dropFailedIndex(indexName)
+		return err
+	}
 
-		if deleteRes.IsError() {
-			log.Fatalf("Error deleting index: %s", deleteRes)
-		}
+	count, err := es.countDocuments(ctx, indexName)
+	if err != nil {
+		es.dropFailedIndex(indexName)
+		return err
+	}
+	if count != sent {
+		es.dropFailedIndex(indexName)
+		return fmt.Errorf("index %s holds %d documents, expected %d; alias %s left unchanged", indexName, count, sent, alias)
+	}
 
-		log.Printf("Index '%s' deleted successfully", indexName)
+	previous, err := es.swapAlias(ctx, alias, indexName)
+	if err != nil {
+		es.dropFailedIndex(indexName)
+		return err
 	}
+	if len(previous) == 0 {
+		log.Printf("Alias '%s' now points to '%s'", alias, indexName)
+	} else {
+		log.Printf("Alias '%s' now points to '%s' (previous: %s)", alias, indexName, strings.Join(previous, ", "))
+	}
+
+	keep := append([]string{indexName}, previous...)
+	if err := es.pruneIndexVersions(ctx, alias, keep); err != nil {
+		// Новая версия уже обслуживает запросы; лишние индексы удалятся при следующей переиндексации
+		log.Printf("WARNING: pruning old versions of '%s': %s", alias, err)
+	}
+	return nil
+}
 
+// createIndex создает индекс мест с настройками и маппингом
+func (es ElasticsearchStore) createIndex(ctx context.Context, indexName string) error {
 	// Creating Index and Starting Mapping
 	fmt.Println("Creating Index and Starting Mapping...")
-	mapping := `{
+	mapping := fmt.Sprintf(`{
+    "_meta": {"mapping_version": %d},
     "properties": {
-        "address": {"type": "text"},
+        "address": {
+            "type": "text",
+            "fields": {
+                "suggest": {"type": "search_as_you_type"}
+            }
+        },
         "phone": {"type": "text"},
-        "name": {"type": "text"},
+        "name": {
+            "type": "text",
+            "fields": {
+                "keyword": {"type": "keyword", "ignore_above": 256},
+                "suggest": {"type": "search_as_you_type"}
+            }
+        },
         "location": {"type": "geo_point"},
-        "id": {"type": "long"}
+        "id": {"type": "long"},
+        "attributes": {"type": "flattened"},
+        "content_hash": {"type": "keyword", "index": false, "doc_values": false}
     }
-}`
+}`, MappingVersion)
 
 	settings := `{
     "number_of_shards": 1,
@@ -83,39 +127,25 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 
 	req := esapi.IndicesCreateRequest{
 		Index: indexName,
-		Body:  strings.NewReader(fmt.Sprintf(`{"settings": %s}`, settings)),
+		Body:  strings.NewReader(fmt.Sprintf(`{"settings": %s, "mappings": %s}`, settings, mapping)),
 	}
 
-	res, err = req.Do(context.Background(), es.client)
+	res, err := req.Do(ctx, es.client)
 	if err != nil {
-		log.Fatalf("Error creating index: %s", err)
+		return transportError("create index", err)
 	}
-	defer res.Body.Close()
-
 	if res.IsError() {
-		log.Fatalf("Error creating index: %s", res)
-	}
-
-	log.Printf("Index '%s' created successfully", indexName)
-
-	reqMapping := esapi.IndicesPutMappingRequest{
-		Index: []string{indexName},
-		Body:  strings.NewReader(mapping),
-	}
-
-	resMapping, err := reqMapping.Do(context.Background(), es.client)
-	if err != nil {
-		log.Fatalf("Error setting mapping: %s", err)
-	}
-	defer resMapping.Body.Close()
-
-	if resMapping.IsError() {
-		log.Fatalf("Error setting mapping: %s", resMapping)
+		return responseError("create index", res)
 	}
+	defer res.Body.Close()
+	return nil
+}
 
-	fmt.Printf("Mapping Index '%s' successfully\n", indexName)
-
+// loadIndex потоково загружает места из it в индекс indexName через BulkIndexer
+// и возвращает количество мест, отправленных на индексацию
+func (es ElasticsearchStore) loadIndex(ctx context.Context, indexName string, it PlaceIterator, opts BulkOptions, numWorkers, flushBytes int) (int, error) {
 	start := time.Now().UTC()
+	progress := newProgress("Indexed", opts.ProgressEvery)
 
 	// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 	//
@@ -125,22 +155,42 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 		Index:         indexName,        // The default index name
 		Client:        es.client,        // The Elasticsearch client
 		NumWorkers:    numWorkers,       // The number of worker goroutines
-		FlushBytes:    int(flushBytes),  // The flush threshold in bytes
+		FlushBytes:    flushBytes,       // The flush threshold in bytes
 		FlushInterval: 30 * time.Second, // The periodic flush interval
 	})
 	if err != nil {
-		log.Fatalf("Error creating the indexer: %s", err)
+		return 0, fmt.Errorf("create bulk indexer: %w", err)
 	}
 	// <<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<
 
+	// Чтение идет в своей горутине; отмена readCtx останавливает его, если загрузка прервалась
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	places, waitReader := streamPlaces(readCtx, it, opts.Buffer)
+
+	// abort закрывает BulkIndexer и дожидается чтения, чтобы не оставить горутины
+	abort := func(err error) (int, error) {
+		stopReading()
+		bi.Close(context.Background())
+		for range places {
+		}
+		waitReader()
+		return 0, err
+	}
+
 	// Loop over the data
 	//
-	for _, a := range data {
+	sent := 0
+	for a := range places {
 		// Prepare the data payload: encode article to JSON
 		//
-		data, err := json.Marshal(a)
+		doc, err := newIndexedPlace(a)
+		if err != nil {
+			return abort(fmt.Errorf("encode place %d: %w", a.ID, err))
+		}
+		data, err := json.Marshal(doc)
 		if err != nil {
-			log.Fatalf("Cannot encode article %d: %s", a.ID, err)
+			return abort(fmt.Errorf("encode place %d: %w", a.ID, err))
 		}
 
 		// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
@@ -148,7 +198,7 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 		// Add an item to the BulkIndexer
 		//
 		err = bi.Add(
-			context.Background(),
+			ctx,
 			esutil.BulkIndexerItem{
 				// Action field configures the operation to perform (index, create, delete, update)
 				Action: "index",
@@ -161,7 +211,7 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 
 				// OnSuccess is called for each successful operation
 				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					atomic.AddUint64(&countSuccessful, 1)
+					progress.add()
 				},
 
 				// OnFailure is called for each failed operation
@@ -175,16 +225,21 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 			},
 		)
 		if err != nil {
-			log.Fatalf("Unexpected error: %s", err)
+			return abort(fmt.Errorf("add place %d to bulk indexer: %w", a.ID, err))
 		}
+		sent++
 		// <<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<
 	}
 
+	if err := waitReader(); err != nil {
+		return abort(fmt.Errorf("reading places: %w", err))
+	}
+
 	// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 	// Close the indexer
 	//
-	if err := bi.Close(context.Background()); err != nil {
-		log.Fatalf("Unexpected error: %s", err)
+	if err := bi.Close(ctx); err != nil {
+		return 0, fmt.Errorf("close bulk indexer: %w", err)
 	}
 	// <<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<
 
@@ -197,19 +252,19 @@ func (es ElasticsearchStore) Indexeres(indexName string) {
 	dur := time.Since(start)
 
 	if biStats.NumFailed > 0 {
-		log.Fatalf(
-			"Indexed [%s] documents with [%s] errors in %s (%s docs/sec)",
+		return 0, fmt.Errorf(
+			"indexed [%s] documents with [%s] errors in %s",
 			humanize.Comma(int64(biStats.NumFlushed)),
 			humanize.Comma(int64(biStats.NumFailed)),
 			dur.Truncate(time.Millisecond),
-			humanize.Comma(int64(1000.0/float64(dur/time.Millisecond)*float64(biStats.NumFlushed))),
-		)
-	} else {
-		log.Printf(
-			"Sucessfuly indexed [%s] documents in %s (%s docs/sec)",
-			humanize.Comma(int64(biStats.NumFlushed)),
-			dur.Truncate(time.Millisecond),
-			humanize.Comma(int64(1000.0/float64(dur/time.Millisecond)*float64(biStats.NumFlushed))),
 		)
 	}
+
+	log.Printf(
+		"Sucessfuly indexed [%s] documents in %s (%s docs/sec)",
+		humanize.Comma(int64(biStats.NumFlushed)),
+		dur.Truncate(time.Millisecond),
+		humanize.Comma(int64(1000.0/float64(dur/time.Millisecond)*float64(biStats.NumFlushed))),
+	)
+	return sent, nil
 }
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIndexeresRefusesEmptySource(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	store := newTestStore(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"acknowledged":true}`)
+	})
+
+	err := store.Indexeres(context.Background(), "places", SlicePlaces(nil), DefaultBulkOptions())
+	if err == nil || !strings.Contains(err.Error(), "alias places left unchanged") {
+		t.Fatalf("got error %v, want a refusal to swap the alias", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var created, deleted string
+	for _, request := range requests {
+		method, path, _ := strings.Cut(request, " ")
+		switch {
+		case strings.Contains(path, "_alias"):
+			t.Errorf("alias touched by %s", request)
+		case method == http.MethodPut:
+			created = path
+		case method == http.MethodDelete:
+			deleted = path
+		}
+	}
+	if created == "" || created != deleted {
+		t.Errorf("created %q, deleted %q; want the empty index dropped (requests %v)", created, deleted, requests)
+	}
+}
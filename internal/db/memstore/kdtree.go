@@ -0,0 +1,213 @@
+package memstore
+
+import (
+	"container/heap"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"math"
+	"sort"
+)
+
+// Оси k-d дерева
+const (
+	axisLat = iota
+	axisLon
+)
+
+// kdNode узел двумерного k-d дерева по широте и долготе
+type kdNode struct {
+	place       int // индекс места в Store.places
+	axis        int
+	left, right *kdNode
+}
+
+// kdTree пространственный индекс мест для поиска ближайших и запросов по прямоугольнику
+type kdTree struct {
+	root   *kdNode
+	places []types.Place
+	// maxAbsLat наибольшая по модулю широта среди мест; нужна для нижней оценки расстояния по долготе
+	maxAbsLat float64
+}
+
+func newKDTree(places []types.Place) *kdTree {
+	t := &kdTree{places: places}
+
+	indexes := make([]int, len(places))
+	for i, place := range places {
+		indexes[i] = i
+		t.maxAbsLat = math.Max(t.maxAbsLat, math.Abs(place.Location.Latitude))
+	}
+	t.root = t.build(indexes, 0)
+	return t
+}
+
+func (t *kdTree) coord(i, axis int) float64 {
+	if axis == axisLat {
+		return t.places[i].Location.Latitude
+	}
+	return t.places[i].Location.Longitude
+}
+
+func (t *kdTree) build(indexes []int, depth int) *kdNode {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	axis := depth % 2
+	sort.Slice(indexes, func(a, b int) bool {
+		return t.coord(indexes[a], axis) < t.coord(indexes[b], axis)
+	})
+
+	mid := len(indexes) / 2
+	return &kdNode{
+		place: indexes[mid],
+		axis:  axis,
+		left:  t.build(indexes[:mid], depth+1),
+		right: t.build(indexes[mid+1:], depth+1),
+	}
+}
+
+// neighbor место и расстояние до него в метрах
+type neighbor struct {
+	place    int
+	distance float64
+}
+
+// neighborHeap max-куча по расстоянию: на вершине самый дальний из найденных
+type neighborHeap []neighbor
+
+func (h neighborHeap) Len() int            { return len(h) }
+func (h neighborHeap) Less(i, j int) bool  { return h[i].distance > h[j].distance }
+func (h neighborHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x interface{}) { *h = append(*h, x.(neighbor)) }
+func (h *neighborHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// planeDistance нижняя оценка расстояния от origin до любой точки по другую сторону
+// разделяющей плоскости узла. По широте это длина дуги меридиана, по долготе - дуга
+// на самой высокой широте, где лежат origin или места индекса. По долготе дальняя сторона
+// доходит до антимеридиана, и через него она может оказаться ближе, чем сама плоскость.
+func (t *kdTree) planeDistance(origin types.GeoJSON, node *kdNode) float64 {
+	if node.axis == axisLat {
+		d := math.Abs(origin.Latitude-t.coord(node.place, axisLat)) * math.Pi / 180
+		return geo.EarthRadius * d
+	}
+
+	split := t.coord(node.place, axisLon)
+	var degrees float64
+	if origin.Longitude >= split {
+		// Дальняя сторона - долготы [-180, split]
+		degrees = math.Min(origin.Longitude-split, 180-origin.Longitude)
+	} else {
+		// Дальняя сторона - долготы [split, 180]
+		degrees = math.Min(split-origin.Longitude, origin.Longitude+180)
+	}
+	d := degrees * math.Pi / 180
+	if d >= math.Pi {
+		return 0
+	}
+	maxLat := math.Max(t.maxAbsLat, math.Abs(origin.Latitude)) * math.Pi / 180
+	return 2 * geo.EarthRadius * math.Asin(math.Cos(maxLat)*math.Sin(d/2))
+}
+
+// nearest возвращает до n ближайших к origin мест не дальше maxDistance метров
+// (maxDistance < 0 - без ограничения), отсортированных по возрастанию расстояния
+func (t *kdTree) nearest(origin types.GeoJSON, n int, maxDistance float64) []neighbor {
+	if n <= 0 {
+		return nil
+	}
+
+	found := &neighborHeap{}
+	var visit func(node *kdNode)
+	visit = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+
+		distance := geo.Distance(origin, t.places[node.place].Location)
+		if maxDistance < 0 || distance <= maxDistance {
+			if found.Len() < n {
+				heap.Push(found, neighbor{place: node.place, distance: distance})
+			} else if distance < (*found)[0].distance {
+				(*found)[0] = neighbor{place: node.place, distance: distance}
+				heap.Fix(found, 0)
+			}
+		}
+
+		var originCoord float64
+		if node.axis == axisLat {
+			originCoord = origin.Latitude
+		} else {
+			originCoord = origin.Longitude
+		}
+
+		near, far := node.left, node.right
+		if originCoord >= t.coord(node.place, node.axis) {
+			near, far = far, near
+		}
+		visit(near)
+
+		// Дальнее поддерево нужно, только если разделяющая плоскость ближе худшего из найденных
+		bound := t.planeDistance(origin, node)
+		if maxDistance >= 0 && bound > maxDistance {
+			return
+		}
+		if found.Len() < n || bound < (*found)[0].distance {
+			visit(far)
+		}
+	}
+	visit(t.root)
+
+	result := make([]neighbor, found.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(found).(neighbor)
+	}
+	return result
+}
+
+// within возвращает индексы мест внутри прямоугольника широт [minLat, maxLat] и долгот [minLon, maxLon]
+func (t *kdTree) within(minLat, maxLat, minLon, maxLon float64) []int {
+	var found []int
+	var visit func(node *kdNode)
+	visit = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+
+		location := t.places[node.place].Location
+		if location.Latitude >= minLat && location.Latitude <= maxLat &&
+			location.Longitude >= minLon && location.Longitude <= maxLon {
+			found = append(found, node.place)
+		}
+
+		low, high := minLat, maxLat
+		if node.axis == axisLon {
+			low, high = minLon, maxLon
+		}
+		split := t.coord(node.place, node.axis)
+		if low <= split {
+			visit(node.left)
+		}
+		if high >= split {
+			visit(node.right)
+		}
+	}
+	visit(t.root)
+	return found
+}
+
+// inBoundingBox возвращает индексы мест внутри прямоугольника карты,
+// в том числе пересекающего антимеридиан
+func (t *kdTree) inBoundingBox(topLeft, bottomRight types.GeoJSON) []int {
+	if topLeft.Longitude <= bottomRight.Longitude {
+		return t.within(bottomRight.Latitude, topLeft.Latitude, topLeft.Longitude, bottomRight.Longitude)
+	}
+	return append(
+		t.within(bottomRight.Latitude, topLeft.Latitude, topLeft.Longitude, 180),
+		t.within(bottomRight.Latitude, topLeft.Latitude, -180, bottomRight.Longitude)...,
+	)
+}
@@ -0,0 +1,156 @@
+package memstore
+
+import (
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// randomPlaces случайные места по всему миру, у антимеридиана и у полюсов.
+// Часть мест стоит в одной точке, чтобы проверить равные расстояния.
+func randomPlaces(n int) []types.Place {
+	rnd := rand.New(rand.NewSource(1))
+	places := make([]types.Place, 0, n)
+	for i := 0; i < n; i++ {
+		var location types.GeoJSON
+		switch {
+		case i%10 == 0 && i > 0:
+			location = places[rnd.Intn(len(places))].Location
+		case i%7 == 0:
+			lon := 179 + rnd.Float64()
+			if rnd.Intn(2) == 0 {
+				lon = -lon
+			}
+			location = types.GeoJSON{Latitude: rnd.Float64()*20 - 10, Longitude: lon}
+		case i%13 == 0:
+			location = types.GeoJSON{Latitude: 85 + rnd.Float64()*5, Longitude: rnd.Float64()*360 - 180}
+		default:
+			location = types.GeoJSON{Latitude: rnd.Float64()*170 - 85, Longitude: rnd.Float64()*360 - 180}
+		}
+		places = append(places, types.Place{ID: i, Name: fmt.Sprintf("Place %03d", rnd.Intn(n)), Location: location})
+	}
+	return places
+}
+
+// bruteNearest все места не дальше maxDistance (maxDistance < 0 - все), по возрастанию расстояния
+func bruteNearest(places []types.Place, origin types.GeoJSON, maxDistance float64) []neighbor {
+	var result []neighbor
+	for i, place := range places {
+		distance := geo.Distance(origin, place.Location)
+		if maxDistance < 0 || distance <= maxDistance {
+			result = append(result, neighbor{place: i, distance: distance})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].distance < result[j].distance })
+	return result
+}
+
+func TestKDTreeNearest(t *testing.T) {
+	places := randomPlaces(500)
+	tree := newKDTree(places)
+
+	origins := []types.GeoJSON{
+		{Latitude: 55.75, Longitude: 37.62},
+		{Latitude: 0, Longitude: 179.95},
+		{Latitude: 5, Longitude: -179.95},
+		{Latitude: -3, Longitude: 180},
+		{Latitude: 89.9, Longitude: 0},
+		{Latitude: -60, Longitude: -100},
+		// Точка, в которой стоит несколько мест
+		places[10].Location,
+	}
+	for _, origin := range origins {
+		for _, n := range []int{1, 3, 20, len(places)} {
+			for _, maxDistance := range []float64{-1, 0, 50000, 500000, 5000000} {
+				name := fmt.Sprintf("origin %v, n %d, max %v", origin, n, maxDistance)
+				got := tree.nearest(origin, n, maxDistance)
+				all := bruteNearest(places, origin, maxDistance)
+				want := all
+				if len(want) > n {
+					want = want[:n]
+				}
+
+				if len(got) != len(want) {
+					t.Fatalf("%s: got %d places, want %d", name, len(got), len(want))
+				}
+				// Среди мест на равном расстоянии порядок не определен: сравниваются расстояния,
+				// а каждое найденное место проверяется отдельно
+				seen := map[int]bool{}
+				for i := range got {
+					if got[i].distance != want[i].distance {
+						t.Fatalf("%s: place %d at %.3fm, want %.3fm", name, i, got[i].distance, want[i].distance)
+					}
+					if d := geo.Distance(origin, places[got[i].place].Location); d != got[i].distance || seen[got[i].place] {
+						t.Fatalf("%s: place %d (index %d) is wrong or repeated", name, i, got[i].place)
+					}
+					seen[got[i].place] = true
+				}
+			}
+		}
+	}
+
+	// Ближайшее место по ту сторону антимеридиана: разделяющие плоскости по долготе далеко,
+	// а само место в двух километрах
+	across := newKDTree([]types.Place{
+		{ID: 0, Location: types.GeoJSON{Latitude: 0, Longitude: -179.99}},
+		{ID: 1, Location: types.GeoJSON{Latitude: 0, Longitude: 0}},
+		{ID: 2, Location: types.GeoJSON{Latitude: 0, Longitude: 170}},
+		{ID: 3, Location: types.GeoJSON{Latitude: 0, Longitude: 100}},
+		{ID: 4, Location: types.GeoJSON{Latitude: 1, Longitude: 50}},
+	})
+	for _, origin := range []types.GeoJSON{{Longitude: 179.99}, {Longitude: -179.95}} {
+		if got := across.nearest(origin, 1, 10000); len(got) != 1 || got[0].place != 0 {
+			t.Errorf("across the antimeridian from %v: got %v, want place 0", origin, got)
+		}
+	}
+
+	if got := tree.nearest(origins[0], 0, -1); len(got) != 0 {
+		t.Errorf("n = 0: got %d places", len(got))
+	}
+}
+
+// bruteBoundingBox индексы мест внутри прямоугольника по порядку
+func bruteBoundingBox(places []types.Place, topLeft, bottomRight types.GeoJSON) []int {
+	result := []int{}
+	for i, place := range places {
+		if geo.InBoundingBox(place.Location, topLeft, bottomRight) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func TestKDTreeInBoundingBox(t *testing.T) {
+	places := randomPlaces(500)
+	tree := newKDTree(places)
+
+	boxes := []struct {
+		name                 string
+		topLeft, bottomRight types.GeoJSON
+	}{
+		{"moscow", types.GeoJSON{Latitude: 60, Longitude: 30}, types.GeoJSON{Latitude: 50, Longitude: 45}},
+		{"whole world", types.GeoJSON{Latitude: 90, Longitude: -180}, types.GeoJSON{Latitude: -90, Longitude: 180}},
+		{"antimeridian", types.GeoJSON{Latitude: 10, Longitude: 179.5}, types.GeoJSON{Latitude: -10, Longitude: -179.5}},
+		{"wide antimeridian", types.GeoJSON{Latitude: 80, Longitude: 100}, types.GeoJSON{Latitude: -80, Longitude: -100}},
+		{"east edge", types.GeoJSON{Latitude: 10, Longitude: 179}, types.GeoJSON{Latitude: -10, Longitude: 180}},
+		{"west edge", types.GeoJSON{Latitude: 10, Longitude: -180}, types.GeoJSON{Latitude: -10, Longitude: -179}},
+		{"polar cap", types.GeoJSON{Latitude: 90, Longitude: -180}, types.GeoJSON{Latitude: 85, Longitude: 180}},
+		{"single point", types.GeoJSON{Latitude: places[10].Location.Latitude, Longitude: places[10].Location.Longitude}, places[10].Location},
+		{"empty", types.GeoJSON{Latitude: 1, Longitude: 1}, types.GeoJSON{Latitude: 1, Longitude: 1}},
+	}
+	for _, box := range boxes {
+		got := tree.inBoundingBox(box.topLeft, box.bottomRight)
+		sort.Ints(got)
+		if got == nil {
+			got = []int{}
+		}
+		want := bruteBoundingBox(places, box.topLeft, box.bottomRight)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %d places %v, want %d %v", box.name, len(got), got, len(want), want)
+		}
+	}
+}
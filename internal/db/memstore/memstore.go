@@ -0,0 +1,260 @@
+// Package memstore реализует хранилище мест в памяти без Elasticsearch.
+// Места загружаются целиком (например, из csvreader.CsvReader), гео-запросы идут через k-d дерево.
+package memstore
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Store хранилище мест в памяти. После создания только читается,
+// поэтому безопасно для одновременных запросов.
+type Store struct {
+	// places места, упорядоченные по ID
+	places []types.Place
+	// byName индексы places, упорядоченные по названию
+	byName []int
+	// byID индекс места в places по его ID
+	byID map[int]int
+	tree *kdTree
+}
+
+var (
+	_ db.Store        = (*Store)(nil)
+	_ db.GeoStore     = (*Store)(nil)
+	_ db.PlaceScanner = (*Store)(nil)
+)
+
+// New строит хранилище и пространственный индекс по списку мест
+func New(places []*types.Place) *Store {
+	s := &Store{
+		places: make([]types.Place, 0, len(places)),
+		byID:   make(map[int]int, len(places)),
+	}
+	for _, place := range places {
+		s.places = append(s.places, *place)
+	}
+	sort.SliceStable(s.places, func(i, j int) bool { return s.places[i].ID < s.places[j].ID })
+
+	s.byName = make([]int, len(s.places))
+	for i, place := range s.places {
+		s.byID[place.ID] = i
+		s.byName[i] = i
+	}
+	sort.SliceStable(s.byName, func(i, j int) bool {
+		return s.places[s.byName[i]].Name < s.places[s.byName[j]].Name
+	})
+
+	s.tree = newKDTree(s.places)
+	return s
+}
+
+// page возвращает места с индексами order[offset:offset+limit]
+func (s *Store) page(order []int, limit, offset int) []types.Place {
+	places := []types.Place{}
+	for i := offset; i < len(order) && i < offset+limit; i++ {
+		places = append(places, s.places[order[i]])
+	}
+	return places
+}
+
+// identity индексы 0..n-1, то есть порядок по ID
+func identity(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// reversed возвращает order в обратном порядке
+func reversed(order []int) []int {
+	result := make([]int, len(order))
+	for i, idx := range order {
+		result[len(order)-1-i] = idx
+	}
+	return result
+}
+
+// GetPlaces Возвращает страницу мест в порядке sort; без сортировки - по ID
+func (s *Store) GetPlaces(ctx context.Context, limit int, offset int, order db.SortOption) ([]types.Place, int, error) {
+	var indexes []int
+	switch order.Field {
+	case db.SortByName:
+		indexes = s.byName
+	case db.SortByDistance:
+		if order.Origin == nil {
+			return nil, 0, fmt.Errorf("%w: distance sort requires an origin", db.ErrBadRequest)
+		}
+		neighbors := s.tree.nearest(*order.Origin, len(s.places), -1)
+		indexes = make([]int, len(neighbors))
+		for i, n := range neighbors {
+			indexes[i] = n.place
+		}
+	default:
+		indexes = identity(len(s.places))
+	}
+	if order.Desc {
+		indexes = reversed(indexes)
+	}
+	return s.page(indexes, limit, offset), len(s.places), nil
+}
+
+// GetPlacesAfter Возвращает места с ID больше, чем в курсоре. Курсор - ID последнего места страницы.
+func (s *Store) GetPlacesAfter(ctx context.Context, limit int, cursor string) ([]types.Place, int, string, error) {
+	start := 0
+	if cursor != "" {
+		lastID, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, 0, "", db.ErrInvalidCursor
+		}
+		start = sort.Search(len(s.places), func(i int) bool { return s.places[i].ID > lastID })
+	}
+
+	places := s.page(identity(len(s.places)), limit, start)
+	next := ""
+	if start+len(places) < len(s.places) && len(places) > 0 {
+		next = strconv.Itoa(places[len(places)-1].ID)
+	}
+	return places, len(s.places), next, nil
+}
+
+// GetPlace Находит место по ID
+func (s *Store) GetPlace(ctx context.Context, id int) (types.Place, error) {
+	i, ok := s.byID[id]
+	if !ok {
+		return types.Place{}, fmt.Errorf("place %d: %w", id, db.ErrNotFound)
+	}
+	return s.places[i], nil
+}
+
+// GetRecommendPlaces Находит limit ближайших мест через k-d дерево, при заданном radius - только внутри него
+func (s *Store) GetRecommendPlaces(ctx context.Context, limit int, lat, lon float64, radius string) ([]types.RecommendedPlace, int, error) {
+	origin := types.GeoJSON{Latitude: lat, Longitude: lon}
+
+	maxDistance := -1.0
+	total := len(s.places)
+	if radius != "" {
+		var err error
+		if maxDistance, err = geo.ParseDistance(radius); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s", db.ErrBadRequest, err)
+		}
+		total = len(s.tree.nearest(origin, len(s.places), maxDistance))
+	}
+
+	neighbors := s.tree.nearest(origin, limit, maxDistance)
+	places := make([]types.RecommendedPlace, 0, len(neighbors))
+	for _, n := range neighbors {
+		places = append(places, types.RecommendedPlace{Place: s.places[n.place], Distance: n.distance})
+	}
+	return places, total, nil
+}
+
+// words приводит текст к словам в нижнем регистре
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == ' ' || r == ',' || r == '.' || r == '"' || r == '«' || r == '»'
+	})
+}
+
+// matchAll проверяет, что каждое слово запроса - префикс какого-либо слова текста
+func matchAll(query, text []string) bool {
+	for _, q := range query {
+		found := false
+		for _, w := range text {
+			if strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(query) > 0
+}
+
+// find возвращает индексы мест, у которых название или адрес содержат все слова запроса
+func (s *Store) find(query string) []int {
+	q := words(query)
+	var found []int
+	for i, place := range s.places {
+		if matchAll(q, words(place.Name+" "+place.Address)) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// Search Ищет места, в названии или адресе которых есть слова, начинающиеся с каждого слова запроса
+func (s *Store) Search(ctx context.Context, query string, limit int, offset int) ([]types.Place, int, error) {
+	found := s.find(query)
+	return s.page(found, limit, offset), len(found), nil
+}
+
+// Suggest Подсказки по началу слов названия или адреса; при заданном origin ближайшие идут первыми
+func (s *Store) Suggest(ctx context.Context, prefix string, limit int, origin *types.GeoJSON) ([]types.Suggestion, error) {
+	found := s.find(prefix)
+	if origin != nil {
+		sort.SliceStable(found, func(i, j int) bool {
+			return geo.Distance(*origin, s.places[found[i]].Location) < geo.Distance(*origin, s.places[found[j]].Location)
+		})
+	}
+
+	suggestions := []types.Suggestion{}
+	for _, place := range s.page(found, limit, 0) {
+		suggestions = append(suggestions, types.Suggestion{
+			ID:       place.ID,
+			Name:     place.Name,
+			Address:  place.Address,
+			Location: place.Location,
+		})
+	}
+	return suggestions, nil
+}
+
+// GetPlacesInBoundingBox Находит места внутри прямоугольника карты
+func (s *Store) GetPlacesInBoundingBox(ctx context.Context, topLeft, bottomRight types.GeoJSON, limit int) ([]types.Place, int, error) {
+	found := s.tree.inBoundingBox(topLeft, bottomRight)
+	sort.Ints(found)
+	return s.page(found, limit, 0), len(found), nil
+}
+
+// GetClusters Группирует места в ячейки geohash заданной точности с центром масс каждой ячейки
+func (s *Store) GetClusters(ctx context.Context, precision int, box *types.BoundingBox) ([]types.Cluster, int, error) {
+	var indexes []int
+	if box != nil {
+		indexes = s.tree.inBoundingBox(box.TopLeft, box.BottomRight)
+	} else {
+		indexes = identity(len(s.places))
+	}
+
+	grid := geo.NewClusterGrid(precision)
+	for _, i := range indexes {
+		grid.Add(s.places[i].Location)
+	}
+	return grid.Clusters(), grid.Total(), nil
+}
+
+// ScanPlaces Обходит все места пачками по batchSize в порядке ID
+func (s *Store) ScanPlaces(ctx context.Context, batchSize int, fn func([]types.Place) error) error {
+	for offset := 0; offset < len(s.places); offset += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := offset + batchSize
+		if end > len(s.places) {
+			end = len(s.places)
+		}
+		if err := fn(s.places[offset:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
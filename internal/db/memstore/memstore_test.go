@@ -0,0 +1,188 @@
+package memstore
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/internal/geo"
+	"elasticTask/pkg/types"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newRandomStore хранилище из randomPlaces, места переданы в перемешанном порядке
+func newRandomStore(n int) (*Store, []types.Place) {
+	places := randomPlaces(n)
+	input := make([]*types.Place, 0, len(places))
+	for i := len(places) - 1; i >= 0; i-- {
+		input = append(input, &places[i])
+	}
+	return New(input), places
+}
+
+func placeIDs(places []types.Place) []int {
+	ids := make([]int, 0, len(places))
+	for _, place := range places {
+		ids = append(ids, place.ID)
+	}
+	return ids
+}
+
+func TestGetPlacesOrder(t *testing.T) {
+	store, places := newRandomStore(300)
+	ctx := context.Background()
+	origin := types.GeoJSON{Latitude: 0, Longitude: 179.9}
+
+	byName := append([]types.Place(nil), places...)
+	sort.SliceStable(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+
+	tests := []struct {
+		name  string
+		order db.SortOption
+		less  func(a, b types.Place) bool
+	}{
+		{"id", db.SortOption{}, func(a, b types.Place) bool { return a.ID < b.ID }},
+		{"id desc", db.SortOption{Field: db.SortByID, Desc: true}, func(a, b types.Place) bool { return a.ID > b.ID }},
+		{"name", db.SortOption{Field: db.SortByName}, func(a, b types.Place) bool { return a.Name < b.Name }},
+		{"name desc", db.SortOption{Field: db.SortByName, Desc: true}, func(a, b types.Place) bool { return a.Name > b.Name }},
+		{"distance", db.SortOption{Field: db.SortByDistance, Origin: &origin}, func(a, b types.Place) bool {
+			return geo.Distance(origin, a.Location) < geo.Distance(origin, b.Location)
+		}},
+	}
+	for _, tt := range tests {
+		var walked []types.Place
+		for offset := 0; ; offset += 40 {
+			page, total, err := store.GetPlaces(ctx, 40, offset, tt.order)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if total != len(places) {
+				t.Fatalf("%s: total %d, want %d", tt.name, total, len(places))
+			}
+			if len(page) == 0 {
+				break
+			}
+			walked = append(walked, page...)
+		}
+
+		if len(walked) != len(places) {
+			t.Fatalf("%s: walked %d places, want %d", tt.name, len(walked), len(places))
+		}
+		for i := 1; i < len(walked); i++ {
+			if tt.less(walked[i], walked[i-1]) {
+				t.Fatalf("%s: place %d (id %d) is out of order", tt.name, i, walked[i].ID)
+			}
+		}
+		ids := placeIDs(walked)
+		sort.Ints(ids)
+		for i, id := range ids {
+			if id != i {
+				t.Fatalf("%s: places are missing or repeated", tt.name)
+			}
+		}
+	}
+
+	if _, _, err := store.GetPlaces(ctx, 10, 0, db.SortOption{Field: db.SortByDistance}); !errors.Is(err, db.ErrBadRequest) {
+		t.Errorf("distance sort without origin: got %v, want ErrBadRequest", err)
+	}
+}
+
+func TestGetPlacesAfter(t *testing.T) {
+	store, places := newRandomStore(95)
+	ctx := context.Background()
+
+	var walked []types.Place
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("cursor pagination does not end")
+		}
+		page, total, next, err := store.GetPlacesAfter(ctx, 10, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != len(places) {
+			t.Errorf("total %d, want %d", total, len(places))
+		}
+		walked = append(walked, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if !reflect.DeepEqual(walked, places) {
+		t.Errorf("walked %v, want every place once in ID order", placeIDs(walked))
+	}
+
+	// Курсор за последним местом - пустая страница без следующего курсора
+	page, _, next, err := store.GetPlacesAfter(ctx, 10, "94")
+	if err != nil || len(page) != 0 || next != "" {
+		t.Errorf("after the last place: %v, next %q, err %v", placeIDs(page), next, err)
+	}
+	if _, _, _, err := store.GetPlacesAfter(ctx, 10, "garbage"); !errors.Is(err, db.ErrInvalidCursor) {
+		t.Errorf("bad cursor: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestGetRecommendPlaces(t *testing.T) {
+	store, places := newRandomStore(300)
+	ctx := context.Background()
+
+	for _, origin := range []types.GeoJSON{{Latitude: 55.75, Longitude: 37.62}, {Latitude: 0, Longitude: -179.95}} {
+		for _, radius := range []string{"", "300km", "3000km", "0m"} {
+			got, total, err := store.GetRecommendPlaces(ctx, 5, origin.Latitude, origin.Longitude, radius)
+			if err != nil {
+				t.Fatalf("radius %q: %v", radius, err)
+			}
+
+			maxDistance := -1.0
+			if radius != "" {
+				maxDistance, _ = geo.ParseDistance(radius)
+			}
+			all := bruteNearest(places, origin, maxDistance)
+			if total != len(all) {
+				t.Errorf("origin %v, radius %q: total %d, want %d", origin, radius, total, len(all))
+			}
+			want := all
+			if len(want) > 5 {
+				want = want[:5]
+			}
+			if len(got) != len(want) {
+				t.Fatalf("origin %v, radius %q: got %d places, want %d", origin, radius, len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Distance != want[i].distance {
+					t.Errorf("origin %v, radius %q, place %d: %.1fm, want %.1fm", origin, radius, i, got[i].Distance, want[i].distance)
+				}
+			}
+		}
+	}
+
+	if _, _, err := store.GetRecommendPlaces(ctx, 5, 0, 0, "far"); !errors.Is(err, db.ErrBadRequest) {
+		t.Errorf("bad radius: got %v, want ErrBadRequest", err)
+	}
+}
+
+func TestGetPlacesInBoundingBox(t *testing.T) {
+	store, places := newRandomStore(500)
+	ctx := context.Background()
+
+	topLeft := types.GeoJSON{Latitude: 20, Longitude: 170}
+	bottomRight := types.GeoJSON{Latitude: -20, Longitude: -170}
+	want := bruteBoundingBox(places, topLeft, bottomRight)
+
+	got, total, err := store.GetPlacesInBoundingBox(ctx, topLeft, bottomRight, len(places))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(want) || !reflect.DeepEqual(placeIDs(got), want) {
+		t.Errorf("got %v of %d, want %v", placeIDs(got), total, want)
+	}
+
+	// limit ограничивает места, но не total; места идут по ID
+	got, total, err = store.GetPlacesInBoundingBox(ctx, topLeft, bottomRight, 3)
+	if err != nil || total != len(want) || !reflect.DeepEqual(placeIDs(got), want[:3]) {
+		t.Errorf("limit 3: got %v of %d, err %v", placeIDs(got), total, err)
+	}
+}
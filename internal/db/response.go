@@ -0,0 +1,192 @@
+package db
+
+import (
+	"bytes"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Значения hits.total.relation
+const (
+	TotalEqual        = "eq"
+	TotalGreaterEqual = "gte"
+)
+
+// SearchResult разобранный ответ поиска Elasticsearch
+type SearchResult struct {
+	// Total количество найденных документов; при TotalRelation == "gte" это нижняя граница
+	Total         int
+	TotalRelation string
+	Hits          []Hit
+	// PitID актуальный идентификатор point-in-time, если запрос его использовал
+	PitID    string
+	TimedOut bool
+	// Aggregations необработанные результаты агрегаций по их именам
+	Aggregations map[string]json.RawMessage
+}
+
+// Hit одно найденное место вместе с оценкой релевантности и значениями сортировки
+type Hit struct {
+	ID    string
+	Place types.Place
+	// Score nil, если Elasticsearch не считал релевантность (например, при явной сортировке)
+	Score *float64
+	Sort  []interface{}
+}
+
+// Places возвращает места из всех hits в порядке ответа
+func (r *SearchResult) Places() []types.Place {
+	places := make([]types.Place, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		places = append(places, hit.Place)
+	}
+	return places
+}
+
+// rawSearchResponse ответ _search в том виде, в котором он приходит по сети
+type rawSearchResponse struct {
+	PitID        string                     `json:"pit_id"`
+	TimedOut     bool                       `json:"timed_out"`
+	Aggregations map[string]json.RawMessage `json:"aggregations"`
+	Hits         *struct {
+		Total *rawTotal `json:"total"`
+		Hits  []rawHit  `json:"hits"`
+	} `json:"hits"`
+}
+
+// rawTotal hits.total: объект {"value", "relation"} или число в старом формате ответа
+type rawTotal struct {
+	Value    *int   `json:"value"`
+	Relation string `json:"relation"`
+}
+
+func (t *rawTotal) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		t.Value = &n
+		t.Relation = TotalEqual
+		return nil
+	}
+
+	type plain rawTotal
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+type rawHit struct {
+	ID     string          `json:"_id"`
+	Score  *float64        `json:"_score"`
+	Sort   []interface{}   `json:"sort"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// rawPlace _source места; указатели позволяют отличить отсутствующее поле от пустого
+type rawPlace struct {
+	ID       *int    `json:"id"`
+	Name     *string `json:"name"`
+	Address  *string `json:"address"`
+	Phone    *string `json:"phone"`
+	Location *struct {
+		Lat *float64 `json:"lat"`
+		Lon *float64 `json:"lon"`
+	} `json:"location"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// DecodeSearchResponse разбирает тело ответа поиска Elasticsearch.
+// Обязательные поля места - id, name и location; отсутствие address или phone не считается ошибкой.
+func DecodeSearchResponse(r io.Reader) (*SearchResult, error) {
+	var raw rawSearchResponse
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode search response: %w", err)
+	}
+	if raw.Hits == nil {
+		return nil, errors.New("decode search response: missing \"hits\" section")
+	}
+
+	result := &SearchResult{
+		PitID:        raw.PitID,
+		TimedOut:     raw.TimedOut,
+		Aggregations: raw.Aggregations,
+		Hits:         make([]Hit, 0, len(raw.Hits.Hits)),
+	}
+
+	// hits.total отсутствует, если track_total_hits выключен
+	if total := raw.Hits.Total; total != nil {
+		if total.Value == nil {
+			return nil, errors.New("decode search response: \"hits.total\" has no \"value\"")
+		}
+		result.Total = *total.Value
+		result.TotalRelation = total.Relation
+		if result.TotalRelation == "" {
+			result.TotalRelation = TotalEqual
+		}
+	}
+
+	for i, h := range raw.Hits.Hits {
+		place, err := decodePlace(h.Source)
+		if err != nil {
+			return nil, fmt.Errorf("decode search response: hit %d (_id %q): %w", i, h.ID, err)
+		}
+		result.Hits = append(result.Hits, Hit{
+			ID:    h.ID,
+			Place: place,
+			Score: h.Score,
+			Sort:  h.Sort,
+		})
+	}
+	return result, nil
+}
+
+func decodePlace(source json.RawMessage) (types.Place, error) {
+	if len(bytes.TrimSpace(source)) == 0 {
+		return types.Place{}, errors.New("missing \"_source\"")
+	}
+
+	var raw rawPlace
+	if err := json.Unmarshal(source, &raw); err != nil {
+		return types.Place{}, fmt.Errorf("malformed \"_source\": %w", err)
+	}
+
+	switch {
+	case raw.ID == nil:
+		return types.Place{}, errors.New("missing field \"id\"")
+	case raw.Name == nil:
+		return types.Place{}, errors.New("missing field \"name\"")
+	case raw.Location == nil:
+		return types.Place{}, errors.New("missing field \"location\"")
+	case raw.Location.Lat == nil || raw.Location.Lon == nil:
+		return types.Place{}, errors.New("field \"location\" must have \"lat\" and \"lon\"")
+	}
+
+	place := types.Place{
+		ID:   *raw.ID,
+		Name: *raw.Name,
+		Location: types.GeoJSON{
+			Latitude:  *raw.Location.Lat,
+			Longitude: *raw.Location.Lon,
+		},
+	}
+	if raw.Address != nil {
+		place.Address = *raw.Address
+	}
+	if raw.Phone != nil {
+		place.Phone = *raw.Phone
+	}
+	place.Attributes = raw.Attributes
+	return place, nil
+}
+
+// sortValueFloat возвращает числовое значение сортировки с индексом i
+func (h Hit) sortValueFloat(i int) (float64, error) {
+	if i >= len(h.Sort) {
+		return 0, fmt.Errorf("hit %q has no sort value %d", h.ID, i)
+	}
+	v, ok := h.Sort[i].(float64)
+	if !ok {
+		return 0, fmt.Errorf("hit %q sort value %d is %T, not a number", h.ID, i, h.Sort[i])
+	}
+	return v, nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import "elasticTask/pkg/types"
+
+// Поля, по которым можно сортировать список мест
+const (
+	SortByName     = "name"
+	SortByID       = "id"
+	SortByDistance = "distance"
+)
+
+// SortOption порядок списка мест.
+// Field - одно из SortBy*; пустое значение оставляет порядок индекса.
+// Origin - точка отсчета, обязательна для SortByDistance.
+type SortOption struct {
+	Field  string
+	Desc   bool
+	Origin *types.GeoJSON
+}
+
+// clause возвращает элемент "sort" запроса Elasticsearch или nil, если сортировка не задана
+func (s SortOption) clause() interface{} {
+	order := "asc"
+	if s.Desc {
+		order = "desc"
+	}
+
+	switch s.Field {
+	case SortByName:
+		return map[string]interface{}{"name.keyword": map[string]interface{}{"order": order}}
+	case SortByID:
+		return map[string]interface{}{"id": map[string]interface{}{"order": order}}
+	case SortByDistance:
+		if s.Origin == nil {
+			return nil
+		}
+		return map[string]interface{}{
+			"_geo_distance": map[string]interface{}{
+				"location": map[string]interface{}{
+					"lat": s.Origin.Latitude,
+					"lon": s.Origin.Longitude,
+				},
+				"order": order,
+				"unit":  "m",
+			},
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MappingVersion версия маппинга индекса мест. Записывается в _meta.mapping_version
+// при создании индекса; увеличивается при изменении маппинга, требующем переиндексации.
+// Версия 2 добавила content_hash для инкрементальной синхронизации, версия 3 - attributes.
+const MappingVersion = 3
+
+// IndexStatus состояние индекса мест
+type IndexStatus struct {
+	// Alias имя, по которому обращается сервер
+	Alias string `json:"alias"`
+	// Indices индексы за алиасом; для индекса без алиаса - он сам
+	Indices []string `json:"indices"`
+	// Documents количество мест
+	Documents int `json:"documents"`
+	// MappingVersion версия маппинга из _meta; 0 - индекс создан до появления версий
+	MappingVersion int `json:"mapping_version"`
+}
+
+// Status Возвращает количество мест и версию маппинга индекса; ErrNotFound, если индекса нет
+func (es ElasticsearchStore) Status(ctx context.Context) (IndexStatus, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	status := IndexStatus{Alias: es.indexName}
+
+	targets, legacy, err := es.aliasTargets(ctx, es.indexName)
+	if err != nil {
+		return status, err
+	}
+	switch {
+	case legacy:
+		status.Indices = []string{es.indexName}
+	case len(targets) == 0:
+		return status, &Error{Op: "status", Kind: ErrNotFound, Reason: fmt.Sprintf("index %s does not exist", es.indexName)}
+	default:
+		status.Indices = targets
+	}
+
+	status.Documents, err = es.countDocuments(ctx, es.indexName)
+	if err != nil {
+		return status, err
+	}
+
+	res, err := es.client.Indices.GetMapping(
+		es.client.Indices.GetMapping.WithContext(ctx),
+		es.client.Indices.GetMapping.WithIndex(status.Indices[0]),
+	)
+	if err != nil {
+		return status, transportError("get mapping", err)
+	}
+	if res.IsError() {
+		return status, responseError("get mapping", res)
+	}
+	defer res.Body.Close()
+
+	// {"places_v20240101000000": {"mappings": {"_meta": {"mapping_version": 1}, "properties": ...}}}
+	var body map[string]struct {
+		Mappings struct {
+			Meta struct {
+				MappingVersion int `json:"mapping_version"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return status, fmt.Errorf("get mapping: decoding response: %w", err)
+	}
+	status.MappingVersion = body[status.Indices[0]].Mappings.Meta.MappingVersion
+	return status, nil
+}
@@ -1,24 +1,69 @@
 package db
 
 import (
+	"context"
 	"elasticTask/pkg/types"
-	"github.com/elastic/go-elasticsearch/v8"
+	"time"
 
+	"github.com/elastic/go-elasticsearch/v8"
 )
 
+// Store хранилище мест, от которого зависят обработчики web.
+// Методы возвращают ошибки, оборачивающие ErrUnavailable, ErrBadRequest, ErrTimeout или ErrNotFound.
 type Store interface {
 	// returns a list of items, a total number of hits and (or) an error in case of one
-	GetPlaces(limit int, offset int) ([]types.Place, int, error)
+	GetPlaces(ctx context.Context, limit int, offset int, sort SortOption) ([]types.Place, int, error)
+	// GetPlacesAfter страница мест по курсору и курсор следующей страницы
+	GetPlacesAfter(ctx context.Context, limit int, cursor string) ([]types.Place, int, string, error)
+	// GetPlace место по идентификатору; ErrNotFound, если его нет
+	GetPlace(ctx context.Context, id int) (types.Place, error)
+	// GetRecommendPlaces ближайшие места с расстоянием до них
+	GetRecommendPlaces(ctx context.Context, limit int, lat, lon float64, radius string) ([]types.RecommendedPlace, int, error)
+	// Search полнотекстовый поиск по названию и адресу
+	Search(ctx context.Context, query string, limit int, offset int) ([]types.Place, int, error)
+	// Suggest подсказки по началу названия или адреса
+	Suggest(ctx context.Context, prefix string, limit int, origin *types.GeoJSON) ([]types.Suggestion, error)
+}
+
+// GeoStore запросы для карт: видимая область и кластеры
+type GeoStore interface {
+	GetPlacesInBoundingBox(ctx context.Context, topLeft, bottomRight types.GeoJSON, limit int) ([]types.Place, int, error)
+	// GetClusters ячейки geohash и количество всех мест в них; если ячеек слишком много,
+	// часть может быть отброшена, и тогда сумма Count ячеек меньше этого количества
+	GetClusters(ctx context.Context, precision int, box *types.BoundingBox) ([]types.Cluster, int, error)
+}
+
+// TileStore векторные тайлы Mapbox со слоем мест
+type TileStore interface {
+	GetTile(ctx context.Context, z, x, y int) ([]byte, error)
+}
+
+// PlaceScanner полный обход всех мест пачками
+type PlaceScanner interface {
+	ScanPlaces(ctx context.Context, batchSize int, fn func([]types.Place) error) error
 }
 
+// Проверка, что ElasticsearchStore реализует все интерфейсы хранилища
+var (
+	_ Store        = (*ElasticsearchStore)(nil)
+	_ GeoStore     = (*ElasticsearchStore)(nil)
+	_ TileStore    = (*ElasticsearchStore)(nil)
+	_ PlaceScanner = (*ElasticsearchStore)(nil)
+)
+
 type ElasticsearchStore struct {
 	client    *elasticsearch.Client
 	indexName string
+	// queryTimeout ограничивает время одного запроса; 0 - без ограничения
+	queryTimeout time.Duration
 }
 
-func NewElasticsearchStore(indexName string) (*ElasticsearchStore, error) {
-	cfg := elasticsearch.Config{
-		Addresses: []string{"http://localhost:9200"},
+// NewElasticsearchStore создает хранилище поверх индекса indexName в кластере из clientConfig.
+// queryTimeout - предельное время одного запроса, передается и в Elasticsearch как параметр timeout; 0 - без ограничения
+func NewElasticsearchStore(clientConfig ClientConfig, indexName string, queryTimeout time.Duration) (*ElasticsearchStore, error) {
+	cfg, err := clientConfig.elasticsearchConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	es, err := elasticsearch.NewClient(cfg)
@@ -27,8 +72,16 @@ func NewElasticsearchStore(indexName string) (*ElasticsearchStore, error) {
 	}
 
 	return &ElasticsearchStore{
-		client:    es,
-		indexName: indexName,
+		client:       es,
+		indexName:    indexName,
+		queryTimeout: queryTimeout,
 	}, nil
 }
 
+// withQueryTimeout ограничивает контекст запроса настроенным временем
+func (es ElasticsearchStore) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if es.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, es.queryTimeout)
+}
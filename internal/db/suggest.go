@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"elasticTask/pkg/types"
+)
+
+// Suggest Находит подсказки для автодополнения по началу названия или адреса места.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// prefix - введенная пользователем часть названия
+// limit - количество подсказок
+// origin - текущее местоположение; если задано, ближайшие места поднимаются выше
+func (es ElasticsearchStore) Suggest(ctx context.Context, prefix string, limit int, origin *types.GeoJSON) ([]types.Suggestion, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	query := map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"query": prefix,
+			"type":  "bool_prefix",
+			"fields": []string{
+				"name.suggest^3",
+				"name.suggest._2gram^3",
+				"name.suggest._3gram^3",
+				"address.suggest",
+				"address.suggest._2gram",
+				"address.suggest._3gram",
+			},
+		},
+	}
+
+	// Ранжирование по близости: оценка умножается на гауссово затухание от точки origin
+	if origin != nil {
+		query = map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query": query,
+				"functions": []map[string]interface{}{
+					{
+						"gauss": map[string]interface{}{
+							"location": map[string]interface{}{
+								"origin": map[string]interface{}{
+									"lat": origin.Latitude,
+									"lon": origin.Longitude,
+								},
+								"scale": "2km",
+								"decay": 0.5,
+							},
+						},
+					},
+				},
+				"boost_mode": "multiply",
+			},
+		}
+	}
+
+	body := map[string]interface{}{
+		"size":    limit,
+		"_source": []string{"id", "name", "address", "location"},
+		"query":   query,
+	}
+
+	res, err := es.doSearch(ctx, "suggest places", body,
+		es.client.Search.WithIndex(es.indexName),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	result, err := decodeSearchResult(res)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]types.Suggestion, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		suggestions = append(suggestions, types.Suggestion{
+			ID:       hit.Place.ID,
+			Name:     hit.Place.Name,
+			Address:  hit.Place.Address,
+			Location: hit.Place.Location,
+		})
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,317 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"elasticTask/pkg/types"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+)
+
+// syncBatchSize количество документов, читаемых из индекса за один запрос при сравнении
+const syncBatchSize = 5000
+
+// indexedPlace документ индекса: место и хеш его содержимого для инкрементальной синхронизации
+type indexedPlace struct {
+	*types.Place
+	ContentHash string `json:"content_hash"`
+}
+
+// newIndexedPlace готовит место к индексации
+func newIndexedPlace(place *types.Place) (indexedPlace, error) {
+	hash, err := contentHash(place)
+	return indexedPlace{Place: place, ContentHash: hash}, err
+}
+
+// contentHash SHA-256 JSON-представления места; меняется при изменении любого поля
+func contentHash(place *types.Place) (string, error) {
+	raw, err := json.Marshal(place)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SyncReport итог синхронизации индекса с CSV
+type SyncReport struct {
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	// Failed количество bulk-операций, отклоненных Elasticsearch
+	Failed int `json:"failed"`
+}
+
+// Sync Потоково сравнивает места из places с индексом по ID и хешу содержимого и отправляет
+// только нужные bulk-операции: index для новых и измененных мест и delete для удаленных.
+// Измененное место заменяется целиком: частичный update оставил бы в документе удаленные из файла атрибуты.
+// Сравнение идет до первой записи в индекс: документы для index копятся во временном файле,
+// и операции отправляются только после успешного чтения всего источника. Поэтому источник,
+// отклоненный в строгом режиме на последней строке, не меняет индекс.
+// ID и хеши всех мест индекса, а также ID уже прочитанных мест держатся в памяти до конца чтения:
+// по ним находятся удаленные места и повторы. Повторное место заменяет предыдущее и в отчете не учитывается.
+// Индекс должен быть создан Indexeres с текущей версией маппинга.
+func (es ElasticsearchStore) Sync(ctx context.Context, places PlaceIterator, opts BulkOptions) (SyncReport, error) {
+	var report SyncReport
+
+	status, err := es.Status(ctx)
+	if err != nil {
+		return report, err
+	}
+	if status.MappingVersion != MappingVersion {
+		return report, fmt.Errorf("index %s has mapping version %d, expected %d; run a full reindex instead of sync",
+			es.indexName, status.MappingVersion, MappingVersion)
+	}
+	if len(status.Indices) != 1 {
+		return report, fmt.Errorf("alias %s points to %d indices; sync needs exactly one", es.indexName, len(status.Indices))
+	}
+
+	indexed, err := es.contentHashes(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	spool, err := os.CreateTemp("", "places-sync-*.ndjson")
+	if err != nil {
+		return report, fmt.Errorf("create sync spool: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	report, err = diffPlaces(ctx, places, indexed, spool)
+	if err != nil {
+		// В индекс еще ничего не отправлено
+		return SyncReport{}, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return SyncReport{}, fmt.Errorf("rewind sync spool: %w", err)
+	}
+
+	bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:      status.Indices[0],
+		Client:     es.client,
+		NumWorkers: runtime.NumCPU(),
+	})
+	if err != nil {
+		return report, fmt.Errorf("create bulk indexer: %w", err)
+	}
+
+	var failed uint64
+	progress := newProgress("Synced", opts.ProgressEvery)
+	onSuccess := func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+		progress.add()
+	}
+	onFailure := func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+		atomic.AddUint64(&failed, 1)
+		if err != nil {
+			log.Printf("ERROR: %s %s: %s", item.Action, item.DocumentID, err)
+		} else {
+			log.Printf("ERROR: %s %s: %s: %s", item.Action, item.DocumentID, res.Error.Type, res.Error.Reason)
+		}
+	}
+	add := func(action string, id int, body []byte) error {
+		item := esutil.BulkIndexerItem{
+			Action:     action,
+			DocumentID: strconv.Itoa(id),
+			OnSuccess:  onSuccess,
+			OnFailure:  onFailure,
+		}
+		if body != nil {
+			item.Body = bytes.NewReader(body)
+		}
+		return bi.Add(ctx, item)
+	}
+	abort := func(err error) (SyncReport, error) {
+		bi.Close(context.Background())
+		return report, err
+	}
+
+	spooled := bufio.NewScanner(spool)
+	spooled.Buffer(make([]byte, 64*1024), maxSpoolLine)
+	for spooled.Scan() {
+		// Строка переиспользуется сканером, а BulkIndexer читает тело позже
+		raw := append([]byte(nil), spooled.Bytes()...)
+		var doc struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return abort(fmt.Errorf("read sync spool: %w", err))
+		}
+		if err := add("index", doc.ID, raw); err != nil {
+			return abort(err)
+		}
+	}
+	if err := spooled.Err(); err != nil {
+		return abort(fmt.Errorf("read sync spool: %w", err))
+	}
+
+	for id := range indexed {
+		if err := add("delete", id, nil); err != nil {
+			return abort(err)
+		}
+	}
+
+	if err := bi.Close(ctx); err != nil {
+		return report, fmt.Errorf("close bulk indexer: %w", err)
+	}
+	report.Failed = int(atomic.LoadUint64(&failed))
+
+	if err := es.refreshIndex(ctx, status.Indices[0]); err != nil {
+		return report, err
+	}
+	if report.Failed > 0 {
+		return report, fmt.Errorf("sync finished with %d failed operations", report.Failed)
+	}
+	return report, nil
+}
+
+// maxSpoolLine максимальная длина документа во временном файле Sync
+const maxSpoolLine = 16 << 20
+
+// diffPlaces читает весь источник и сравнивает его с хешами indexed, ничего не отправляя в индекс.
+// Документы новых и измененных мест пишутся в spool по одному в строке; встреченные места
+// убираются из indexed, так что в конце в нем остаются только удаленные.
+func diffPlaces(ctx context.Context, places PlaceIterator, indexed map[int]string, spool io.Writer) (SyncReport, error) {
+	var report SyncReport
+	w := bufio.NewWriter(spool)
+	seen := map[int]struct{}{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		place, err := places.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("reading places: %w", err)
+		}
+
+		doc, err := newIndexedPlace(place)
+		if err != nil {
+			return report, fmt.Errorf("encode place %d: %w", place.ID, err)
+		}
+
+		_, duplicate := seen[place.ID]
+		seen[place.ID] = struct{}{}
+		hash, ok := indexed[place.ID]
+		delete(indexed, place.ID)
+
+		write := true
+		switch {
+		case duplicate:
+			// Последнее из повторяющихся мест остается в индексе, как и при полной загрузке
+		case !ok:
+			report.Added++
+		case hash != doc.ContentHash:
+			report.Changed++
+		default:
+			report.Unchanged++
+			write = false
+		}
+		if !write {
+			continue
+		}
+
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return report, fmt.Errorf("encode place %d: %w", place.ID, err)
+		}
+		w.Write(raw)
+		if err := w.WriteByte('\n'); err != nil {
+			return report, fmt.Errorf("write sync spool: %w", err)
+		}
+	}
+
+	report.Removed = len(indexed)
+	if err := w.Flush(); err != nil {
+		return report, fmt.Errorf("write sync spool: %w", err)
+	}
+	return report, nil
+}
+
+// contentHashes читает из индекса хеши содержимого всех мест по их ID
+func (es ElasticsearchStore) contentHashes(ctx context.Context) (map[int]string, error) {
+	pitID, err := es.openPointInTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// ctx может быть уже отменен, а point-in-time все равно нужно освободить
+	defer func() { es.closePointInTime(context.Background(), pitID) }()
+
+	hashes := map[int]string{}
+	var after []interface{}
+	for {
+		query := map[string]interface{}{
+			"size":    syncBatchSize,
+			"_source": []string{"id", "content_hash"},
+			"query": map[string]interface{}{
+				"match_all": map[string]interface{}{},
+			},
+			"pit": map[string]interface{}{
+				"id":         pitID,
+				"keep_alive": pitKeepAlive,
+			},
+			"sort": []map[string]interface{}{
+				{"id": "asc"},
+			},
+		}
+		if len(after) > 0 {
+			query["search_after"] = after
+		}
+
+		res, err := es.doSearch(ctx, "read content hashes", query)
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			PitID string `json:"pit_id"`
+			Hits  struct {
+				Hits []struct {
+					ID     string `json:"_id"`
+					Source struct {
+						ID          *int   `json:"id"`
+						ContentHash string `json:"content_hash"`
+					} `json:"_source"`
+					Sort []interface{} `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read content hashes: decoding response: %w", err)
+		}
+		if body.PitID != "" {
+			pitID = body.PitID
+		}
+
+		for _, hit := range body.Hits.Hits {
+			if hit.Source.ID == nil {
+				return nil, fmt.Errorf("read content hashes: document %q has no \"id\"", hit.ID)
+			}
+			// Документы без хеша (загруженные до его появления) считаются измененными
+			hashes[*hit.Source.ID] = hit.Source.ContentHash
+		}
+
+		if len(body.Hits.Hits) < syncBatchSize {
+			return hashes, nil
+		}
+		after = body.Hits.Hits[len(body.Hits.Hits)-1].Sort
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bulkAction одна операция из тела _bulk
+type bulkAction struct {
+	Action string
+	ID     string
+	Doc    map[string]interface{}
+}
+
+// syncServer фейковый кластер с индексом places_v1 за алиасом places.
+// hashes - хеши содержимого проиндексированных мест; операции _bulk записываются в actions.
+func syncServer(t *testing.T, hashes map[int]string, mu *sync.Mutex, actions *[]bulkAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/_alias/places":
+			io.WriteString(w, `{"places_v1":{"aliases":{"places":{}}}}`)
+		case r.URL.Path == "/places/_count":
+			fmt.Fprintf(w, `{"count":%d}`, len(hashes))
+		case r.URL.Path == "/places_v1/_mapping":
+			fmt.Fprintf(w, `{"places_v1":{"mappings":{"_meta":{"mapping_version":%d}}}}`, MappingVersion)
+		case r.URL.Path == "/places/_pit":
+			io.WriteString(w, `{"id":"pit-1"}`)
+		case r.URL.Path == "/_pit":
+			io.WriteString(w, `{"succeeded":true}`)
+		case r.URL.Path == "/_search":
+			var hits []string
+			for id, hash := range hashes {
+				hits = append(hits, fmt.Sprintf(`{"_id":"%d","_source":{"id":%d,"content_hash":%q},"sort":[%d]}`, id, id, hash, id))
+			}
+			fmt.Fprintf(w, `{"pit_id":"pit-1","hits":{"hits":[%s]}}`, strings.Join(hits, ","))
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			var items []string
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var meta map[string]struct {
+					ID string `json:"_id"`
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+					t.Errorf("bulk action line %q: %v", scanner.Text(), err)
+					return
+				}
+				for action, target := range meta {
+					item := bulkAction{Action: action, ID: target.ID}
+					if action != "delete" && scanner.Scan() {
+						json.Unmarshal(scanner.Bytes(), &item.Doc)
+					}
+					mu.Lock()
+					*actions = append(*actions, item)
+					mu.Unlock()
+					items = append(items, fmt.Sprintf(`{%q:{"_id":%q,"status":200}}`, action, target.ID))
+				}
+			}
+			fmt.Fprintf(w, `{"errors":false,"items":[%s]}`, strings.Join(items, ","))
+		case strings.HasSuffix(r.URL.Path, "/_refresh"):
+			io.WriteString(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestSyncReplacesChangedPlaces(t *testing.T) {
+	location := types.GeoJSON{Latitude: 55.75, Longitude: 37.61}
+	unchanged := &types.Place{ID: 2, Name: "Rodnik", Location: location}
+	unchangedHash, err := contentHash(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Место 1 в индексе с атрибутами cuisine и wifi, в файле у него остался только cuisine
+	changed := &types.Place{ID: 1, Name: "SMETANA", Location: location, Attributes: map[string]string{"cuisine": "russian"}}
+	added := &types.Place{ID: 4, Name: "Bar", Location: location}
+	hashes := map[int]string{1: "stale", 2: unchangedHash, 3: "removed"}
+
+	var (
+		mu      sync.Mutex
+		actions []bulkAction
+	)
+	store := newTestStore(t, 0, syncServer(t, hashes, &mu, &actions))
+
+	report, err := store.Sync(context.Background(), SlicePlaces([]*types.Place{changed, unchanged, added}), DefaultBulkOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (SyncReport{Added: 1, Changed: 1, Removed: 1, Unchanged: 1}) {
+		t.Errorf("report %+v", report)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	byID := map[string]bulkAction{}
+	for _, action := range actions {
+		byID[action.ID] = action
+	}
+	if len(actions) != 3 || byID["1"].Action != "index" || byID["4"].Action != "index" || byID["3"].Action != "delete" {
+		t.Fatalf("bulk actions %+v, want index 1 and 4, delete 3", actions)
+	}
+
+	// Измененное место отправлено целиком: документ заменяется, старые атрибуты не сохраняются
+	doc := byID["1"].Doc
+	if _, partial := doc["doc"]; partial {
+		t.Fatalf("changed place sent as a partial update: %v", doc)
+	}
+	if doc["name"] != "SMETANA" || fmt.Sprint(doc["attributes"]) != "map[cuisine:russian]" || doc["content_hash"] == "" {
+		t.Errorf("changed place document %v", doc)
+	}
+}
+
+func TestSyncStrictRejectLeavesIndexUnchanged(t *testing.T) {
+	hashes := map[int]string{1: "stale", 3: "removed"}
+	var (
+		mu      sync.Mutex
+		actions []bulkAction
+	)
+	store := newTestStore(t, 0, syncServer(t, hashes, &mu, &actions))
+
+	// Строгий режим отклоняет файл только в конце чтения, когда первые строки уже сравнены
+	file := "\tName\tAddress\tPhone\tLongitude\tLatitude\n" +
+		"1\tSMETANA\tul. Pravdy, 8\t\t37.61\t55.75\n" +
+		"4\tBar\tul. Lesnaja, 5\t\t37.59\t55.78\n" +
+		"5\tBroken\t\t\tnot-a-number\t55.70\n"
+	reader, err := csvreader.NewReader(strings.NewReader(file), csvreader.Options{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.Sync(context.Background(), reader, DefaultBulkOptions())
+	var validationErr *csvreader.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want *csvreader.ValidationError", err)
+	}
+	if report != (SyncReport{}) {
+		t.Errorf("report %+v, want nothing applied", report)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(actions) != 0 {
+		t.Errorf("bulk actions sent for a rejected file: %+v", actions)
+	}
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// tileHitsMinZoom начиная с этого уровня масштаба в тайле отдаются отдельные места,
+// на меньших уровнях - только агрегированные ячейки сетки
+const tileHitsMinZoom = 13
+
+// GetTile Возвращает векторный тайл Mapbox (pbf) со слоем мест из API _mvt Elasticsearch.
+// ctx - контекст запроса; его отмена прерывает поиск в Elasticsearch
+// z, x, y - координаты тайла в схеме XYZ
+func (es ElasticsearchStore) GetTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	ctx, cancel := es.withQueryTimeout(ctx)
+	defer cancel()
+
+	body := map[string]interface{}{
+		"fields": []string{"id", "name"},
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, err
+	}
+
+	options := []func(*esapi.SearchMvtRequest){
+		es.client.SearchMvt.WithContext(ctx),
+		es.client.SearchMvt.WithBody(&buf),
+		es.client.SearchMvt.WithExactBounds(true),
+		es.client.SearchMvt.WithTrackTotalHits(false),
+	}
+	if z >= tileHitsMinZoom {
+		options = append(options,
+			es.client.SearchMvt.WithSize(10000),
+			es.client.SearchMvt.WithGridPrecision(0),
+		)
+	} else {
+		options = append(options,
+			es.client.SearchMvt.WithSize(0),
+			es.client.SearchMvt.WithGridPrecision(8),
+			es.client.SearchMvt.WithGridType("grid"),
+		)
+	}
+
+	res, err := es.client.SearchMvt([]string{es.indexName}, "location", &x, &y, &z, options...)
+	if err != nil {
+		return nil, transportError("vector tile", err)
+	}
+	if res.IsError() {
+		return nil, responseError("vector tile", res)
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
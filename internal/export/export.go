@@ -0,0 +1,215 @@
+package export
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"elasticTask/pkg/types"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Поддерживаемые форматы выгрузки
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatGeoJSON = "geojson"
+)
+
+// Writer построчно записывает места в выбранном формате.
+// Close дописывает завершающую часть документа и сбрасывает буферы.
+type Writer interface {
+	Write(place types.Place) error
+	Close() error
+}
+
+// NewWriter создает Writer для формата format поверх w.
+// attributes - ключи Place.Attributes, которые CSV пишет дополнительными колонками после основных
+// (см. AttributeKeys); ndjson и geojson пишут атрибуты каждого места целиком и этот параметр не используют.
+func NewWriter(format string, w io.Writer, attributes []string) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return newCSVWriter(w, attributes), nil
+	case FormatGeoJSON:
+		return &geojsonWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// AttributeKeys отдельным проходом по всем местам собирает отсортированные ключи их атрибутов.
+// Заголовок CSV пишется до первой строки, поэтому колонки атрибутов нужно знать заранее.
+func AttributeKeys(ctx context.Context, scanner db.PlaceScanner, batchSize int) ([]string, error) {
+	seen := map[string]bool{}
+	err := scanner.ScanPlaces(ctx, batchSize, func(places []types.Place) error {
+		for _, place := range places {
+			for key := range place.Attributes {
+				seen[key] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ContentType возвращает MIME-тип для формата выгрузки
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/tab-separated-values; charset=utf-8"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// FileExtension возвращает расширение файла для формата выгрузки
+func FileExtension(format string) string {
+	if format == FormatCSV {
+		return "csv"
+	}
+	return format
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(place types.Place) error {
+	return n.enc.Encode(place)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// csvWriter пишет места в той же раскладке с табуляцией, которую читает csvreader.CsvReader,
+// чтобы выгрузку можно было загрузить обратно. Атрибуты идут колонками после основных полей:
+// csvreader вернет их в Attributes. Атрибуты с ключами не из attributes не выгружаются.
+type csvWriter struct {
+	w           *csv.Writer
+	attributes  []string
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer, attributes []string) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = '\t'
+	return &csvWriter{w: cw, attributes: attributes}
+}
+
+// writeHeader пишет заголовок один раз, перед первой строкой
+func (c *csvWriter) writeHeader() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.w.Write(append([]string{"", "Name", "Address", "Phone", "Longitude", "Latitude"}, c.attributes...))
+}
+
+func (c *csvWriter) Write(place types.Place) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := []string{
+		strconv.Itoa(place.ID),
+		place.Name,
+		place.Address,
+		place.Phone,
+		strconv.FormatFloat(place.Location.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(place.Location.Latitude, 'f', -1, 64),
+	}
+	for _, key := range c.attributes {
+		record = append(record, place.Attributes[key])
+	}
+	return c.w.Write(record)
+}
+
+// Close дописывает заголовок, если мест не было: пустой файл csvreader не читает,
+// а выгрузка пустого индекса должна загружаться обратно
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// geojsonWriter пишет FeatureCollection потоково: заголовок, объекты через запятую и закрывающую скобку
+type geojsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+type geojsonFeature struct {
+	Type       string            `json:"type"`
+	ID         int               `json:"id"`
+	Geometry   geojsonGeometry   `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+type geojsonGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func (g *geojsonWriter) Write(place types.Place) error {
+	prefix := ","
+	if g.count == 0 {
+		prefix = `{"type":"FeatureCollection","features":[`
+	}
+	g.count++
+
+	// Дополнительные колонки не перекрывают основные поля
+	properties := make(map[string]string, len(place.Attributes)+3)
+	for key, value := range place.Attributes {
+		properties[key] = value
+	}
+	properties["name"] = place.Name
+	properties["address"] = place.Address
+	properties["phone"] = place.Phone
+
+	feature, err := json.Marshal(geojsonFeature{
+		Type: "Feature",
+		ID:   place.ID,
+		Geometry: geojsonGeometry{
+			Type:        "Point",
+			Coordinates: [2]float64{place.Location.Longitude, place.Location.Latitude},
+		},
+		Properties: properties,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(g.w, prefix); err != nil {
+		return err
+	}
+	_, err = g.w.Write(feature)
+	return err
+}
+
+func (g *geojsonWriter) Close() error {
+	tail := "]}\n"
+	if g.count == 0 {
+		tail = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+	_, err := io.WriteString(g.w, tail)
+	return err
+}
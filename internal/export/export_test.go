@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"elasticTask/internal/csvreader"
+	"elasticTask/internal/db/memstore"
+	"elasticTask/internal/placereader"
+	"elasticTask/pkg/types"
+	"reflect"
+	"testing"
+)
+
+func TestCSVRoundTripKeepsAttributes(t *testing.T) {
+	places := []*types.Place{
+		{ID: 1, Name: "SMETANA", Address: "ul. Pravdy, 8", Phone: "(495) 111-22-33",
+			Location: types.GeoJSON{Latitude: 55.75, Longitude: 37.61}, Attributes: map[string]string{"cuisine": "russian", "wifi": "yes"}},
+		{ID: 2, Name: "Rodnik", Location: types.GeoJSON{Latitude: 55.7, Longitude: 37.5}},
+		{ID: 3, Name: "Bar", Location: types.GeoJSON{Latitude: 55.8, Longitude: 37.7}, Attributes: map[string]string{"wifi": "no"}},
+	}
+
+	keys, err := AttributeKeys(context.Background(), memstore.New(places), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"cuisine", "wifi"}) {
+		t.Fatalf("attribute keys %v", keys)
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(FormatCSV, &buf, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, place := range places {
+		if err := writer.Write(*place); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Выгрузка читается обратно без потерь: пустые ячейки атрибутов не попадают в Attributes
+	got, report, err := csvreader.Read(&buf, csvreader.Options{Strict: true})
+	if err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	if report.RejectedRows() != 0 || !reflect.DeepEqual(got, places) {
+		t.Errorf("read back %+v, want %+v", got, places)
+	}
+}
+
+func TestEmptyExportRoundTrip(t *testing.T) {
+	for format, readAs := range map[string]string{
+		FormatCSV:     placereader.FormatCSV,
+		FormatNDJSON:  placereader.FormatJSONL,
+		FormatGeoJSON: placereader.FormatGeoJSON,
+	} {
+		var buf bytes.Buffer
+		writer, err := NewWriter(format, &buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		// Выгрузка пустого индекса загружается обратно без ошибок и без мест
+		reader, err := placereader.NewReader(&buf, readAs, csvreader.Options{Strict: true})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		places, err := placereader.ReadAll(reader)
+		if err != nil || len(places) != 0 {
+			t.Errorf("%s: read back %d places, err %v", format, len(places), err)
+		}
+	}
+}
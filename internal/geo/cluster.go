@@ -0,0 +1,65 @@
+package geo
+
+import (
+	"elasticTask/pkg/types"
+	"sort"
+)
+
+// ClusterGrid собирает места в ячейки geohash заданной точности с центром масс каждой ячейки,
+// как агрегация geohash_grid с geo_centroid в Elasticsearch
+type ClusterGrid struct {
+	precision int
+	cells     map[string]*clusterCell
+	total     int
+}
+
+type clusterCell struct {
+	count    int
+	lat, lon float64
+}
+
+// NewClusterGrid создает пустую сетку точности precision
+func NewClusterGrid(precision int) *ClusterGrid {
+	return &ClusterGrid{precision: precision, cells: map[string]*clusterCell{}}
+}
+
+// Add добавляет место в его ячейку
+func (g *ClusterGrid) Add(location types.GeoJSON) {
+	hash := Geohash(location.Latitude, location.Longitude, g.precision)
+	c, ok := g.cells[hash]
+	if !ok {
+		c = &clusterCell{}
+		g.cells[hash] = c
+	}
+	c.count++
+	c.lat += location.Latitude
+	c.lon += location.Longitude
+	g.total++
+}
+
+// Total количество добавленных мест
+func (g *ClusterGrid) Total() int {
+	return g.total
+}
+
+// Clusters возвращает непустые ячейки; как в geohash_grid, сначала самые наполненные
+func (g *ClusterGrid) Clusters() []types.Cluster {
+	clusters := make([]types.Cluster, 0, len(g.cells))
+	for hash, c := range g.cells {
+		clusters = append(clusters, types.Cluster{
+			Geohash: hash,
+			Count:   c.count,
+			Centroid: types.GeoJSON{
+				Latitude:  c.lat / float64(c.count),
+				Longitude: c.lon / float64(c.count),
+			},
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Geohash < clusters[j].Geohash
+	})
+	return clusters
+}
@@ -0,0 +1,71 @@
+package geo
+
+import (
+	"elasticTask/pkg/types"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadius средний радиус Земли в метрах, как в arcDistance Elasticsearch
+const EarthRadius = 6371008.7714
+
+// Distance расстояние между двумя точками по дуге большого круга в метрах
+func Distance(a, b types.GeoJSON) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// InBoundingBox проверяет, лежит ли точка внутри прямоугольника карты
+func InBoundingBox(p types.GeoJSON, topLeft, bottomRight types.GeoJSON) bool {
+	if p.Latitude > topLeft.Latitude || p.Latitude < bottomRight.Latitude {
+		return false
+	}
+	// Прямоугольник может пересекать антимеридиан
+	if topLeft.Longitude <= bottomRight.Longitude {
+		return p.Longitude >= topLeft.Longitude && p.Longitude <= bottomRight.Longitude
+	}
+	return p.Longitude >= topLeft.Longitude || p.Longitude <= bottomRight.Longitude
+}
+
+// distanceUnits множители единиц расстояния Elasticsearch в метры
+var distanceUnits = map[string]float64{
+	"mi": 1609.344, "miles": 1609.344,
+	"yd": 0.9144, "yards": 0.9144,
+	"ft": 0.3048, "feet": 0.3048,
+	"in": 0.0254, "inch": 0.0254,
+	"km": 1000, "kilometers": 1000,
+	"m": 1, "meters": 1,
+	"cm": 0.01, "centimeters": 0.01,
+	"mm": 0.001, "millimeters": 0.001,
+	"nmi": 1852, "NM": 1852,
+}
+
+// ParseDistance переводит расстояние в формате Elasticsearch ("500m", "2km", "1.5mi") в метры.
+// Число без единиц считается метрами.
+func ParseDistance(s string) (float64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := s, "m"
+	if i >= 0 {
+		number, unit = s[:i], s[i:]
+	}
+
+	factor, ok := distanceUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown distance unit in %q", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid distance %q", s)
+	}
+	return value * factor, nil
+}
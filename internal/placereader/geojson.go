@@ -0,0 +1,194 @@
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// geoJSONDecoder читает объекты массива features из GeoJSON FeatureCollection, не загружая файл целиком.
+// Вместо номера строки в отчете указывается порядковый номер объекта.
+type geoJSONDecoder struct {
+	dec      *json.Decoder
+	started  bool
+	finished bool
+	feature  int
+}
+
+type geoJSONFeature struct {
+	Type     string          `json:"type"`
+	ID       json.RawMessage `json:"id"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+func newGeoJSONDecoder(r io.Reader) *geoJSONDecoder {
+	return &geoJSONDecoder{dec: json.NewDecoder(r)}
+}
+
+func (d *geoJSONDecoder) next() (*types.Place, int, []csvreader.RowError, error) {
+	if d.finished {
+		return nil, 0, nil, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		if err := d.seekFeatures(); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+	if !d.dec.More() {
+		d.finished = true
+		if err := d.finish(); err != nil {
+			return nil, 0, nil, err
+		}
+		return nil, 0, nil, io.EOF
+	}
+
+	d.feature++
+	var feature geoJSONFeature
+	if err := d.dec.Decode(&feature); err != nil {
+		return nil, 0, nil, fmt.Errorf("decoding GeoJSON feature %d: %w", d.feature, err)
+	}
+	place, rejected := feature.place(d.feature)
+	return place, d.feature, rejected, nil
+}
+
+// seekFeatures переходит к первому элементу массива features
+func (d *geoJSONDecoder) seekFeatures() error {
+	if err := d.expectDelim('{'); err != nil {
+		return err
+	}
+	found, err := d.members(true)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("GeoJSON has no \"features\" member; want a FeatureCollection")
+	}
+	return d.expectDelim('[')
+}
+
+// finish дочитывает объект после массива features: type может идти и после него
+func (d *geoJSONDecoder) finish() error {
+	if err := d.expectDelim(']'); err != nil {
+		return err
+	}
+	if _, err := d.members(false); err != nil {
+		return err
+	}
+	return d.expectDelim('}')
+}
+
+// members читает члены объекта верхнего уровня и проверяет type. С atFeatures останавливается
+// перед значением features и возвращает true; без него повторный features - ошибка.
+func (d *geoJSONDecoder) members(atFeatures bool) (bool, error) {
+	for d.dec.More() {
+		token, err := d.dec.Token()
+		if err != nil {
+			return false, fmt.Errorf("decoding GeoJSON: %w", err)
+		}
+		switch key, _ := token.(string); key {
+		case "type":
+			var kind string
+			if err := d.dec.Decode(&kind); err != nil {
+				return false, fmt.Errorf("decoding GeoJSON type: %w", err)
+			}
+			if kind != "FeatureCollection" {
+				return false, fmt.Errorf("GeoJSON type is %q, want FeatureCollection", kind)
+			}
+		case "features":
+			if !atFeatures {
+				return false, errors.New("GeoJSON has more than one \"features\" member")
+			}
+			return true, nil
+		default:
+			var skip json.RawMessage
+			if err := d.dec.Decode(&skip); err != nil {
+				return false, fmt.Errorf("decoding GeoJSON member %q: %w", key, err)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (d *geoJSONDecoder) expectDelim(want json.Delim) error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return fmt.Errorf("decoding GeoJSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("decoding GeoJSON: expected %q, got %v", want, token)
+	}
+	return nil
+}
+
+// place переводит объект в место: id берется из properties.id или из id объекта,
+// координаты - из геометрии Point, свойства кроме name, address и phone попадают в Attributes
+func (f *geoJSONFeature) place(line int) (*types.Place, []csvreader.RowError) {
+	var errs []csvreader.RowError
+	fail := func(column, format string, a ...interface{}) {
+		errs = append(errs, csvreader.RowError{Line: line, Column: column, Reason: fmt.Sprintf(format, a...)})
+	}
+
+	if f.Type != "Feature" {
+		fail("type", "type is %q, want Feature", f.Type)
+		return nil, errs
+	}
+
+	place := &types.Place{}
+	rawID, ok := f.Properties["id"]
+	if !ok || string(rawID) == "null" {
+		rawID = f.ID
+	}
+	if len(rawID) == 0 || string(rawID) == "null" {
+		fail("id", "required field is missing")
+	} else if id, err := parseID(rawID); err != nil {
+		fail("id", "%v", err)
+	} else {
+		place.ID = id
+	}
+
+	var coordinates []float64
+	switch {
+	case f.Geometry == nil:
+		fail("geometry", "required field is missing")
+	case f.Geometry.Type != "Point":
+		fail("geometry", "type is %q, want Point", f.Geometry.Type)
+	case json.Unmarshal(f.Geometry.Coordinates, &coordinates) != nil || len(coordinates) < 2:
+		fail("geometry", "coordinates must be [longitude, latitude]")
+	default:
+		place.Location = types.GeoJSON{Longitude: coordinates[0], Latitude: coordinates[1]}
+	}
+
+	for key, raw := range f.Properties {
+		if string(raw) == "null" {
+			continue
+		}
+		value := attributeValue(raw)
+		switch key {
+		case "id":
+		case "name":
+			place.Name = value
+		case "address":
+			place.Address = value
+		case "phone":
+			place.Phone = value
+		default:
+			if place.Attributes == nil {
+				place.Attributes = map[string]string{}
+			}
+			place.Attributes[key] = value
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return place, nil
+}
@@ -0,0 +1,46 @@
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"strings"
+	"testing"
+)
+
+func TestGeoJSONTopLevelObject(t *testing.T) {
+	const feature = `{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[37.61,55.75]},"properties":{"name":"SMETANA"}}`
+	tests := []struct {
+		name    string
+		input   string
+		places  int
+		wantErr string
+	}{
+		{"type first", `{"type":"FeatureCollection","features":[` + feature + `]}`, 1, ""},
+		{"type after features", `{"features":[` + feature + `],"bbox":[0,0,1,1],"type":"FeatureCollection"}`, 1, ""},
+		{"empty collection", `{"type":"FeatureCollection","features":[]}`, 0, ""},
+		{"no features", `{"type":"FeatureCollection"}`, 0, `no "features" member`},
+		{"not a collection", `{"type":"Feature","geometry":null,"properties":{}}`, 0, `type is "Feature"`},
+		{"wrong type after features", `{"features":[` + feature + `],"type":"Feature"}`, 0, `type is "Feature"`},
+		{"duplicate features", `{"features":[],"features":[` + feature + `]}`, 0, `more than one "features"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(tt.input), FormatGeoJSON, csvreader.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			places, err := ReadAll(reader)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(places) != tt.places {
+				t.Errorf("got %d places, want %d", len(places), tt.places)
+			}
+		})
+	}
+}
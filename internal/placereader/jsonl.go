@@ -0,0 +1,93 @@
+package placereader
+
+import (
+	"bufio"
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxJSONLine максимальная длина строки JSON Lines
+const maxJSONLine = 1 << 20
+
+// jsonlDecoder читает по одному месту в строке в том же виде, что и экспорт в ndjson
+type jsonlDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+type jsonlPlace struct {
+	ID         json.RawMessage   `json:"id"`
+	Name       string            `json:"name"`
+	Address    string            `json:"address"`
+	Phone      string            `json:"phone"`
+	Location   *jsonlLocation    `json:"location"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// jsonlLocation координаты с указателями: пропущенная координата не должна превратиться в 0
+type jsonlLocation struct {
+	Lat *float64 `json:"lat"`
+	Lon *float64 `json:"lon"`
+}
+
+func newJSONLDecoder(r io.Reader) *jsonlDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLine)
+	return &jsonlDecoder{scanner: scanner}
+}
+
+func (d *jsonlDecoder) next() (*types.Place, int, []csvreader.RowError, error) {
+	for d.scanner.Scan() {
+		d.line++
+		text := strings.TrimSpace(d.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var raw jsonlPlace
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, d.line, []csvreader.RowError{{Line: d.line, Reason: err.Error()}}, nil
+		}
+		place, rejected := raw.place(d.line)
+		return place, d.line, rejected, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, 0, nil, fmt.Errorf("reading JSON Lines: %w", err)
+	}
+	return nil, 0, nil, io.EOF
+}
+
+func (p *jsonlPlace) place(line int) (*types.Place, []csvreader.RowError) {
+	var errs []csvreader.RowError
+	place := &types.Place{Name: p.Name, Address: p.Address, Phone: p.Phone, Attributes: p.Attributes}
+
+	if len(p.ID) == 0 || string(p.ID) == "null" {
+		errs = append(errs, csvreader.RowError{Line: line, Column: "id", Reason: "required field is missing"})
+	} else if id, err := parseID(p.ID); err != nil {
+		errs = append(errs, csvreader.RowError{Line: line, Column: "id", Reason: err.Error()})
+	} else {
+		place.ID = id
+	}
+	switch {
+	case p.Location == nil:
+		errs = append(errs, csvreader.RowError{Line: line, Column: "location", Reason: "required field is missing"})
+	case p.Location.Lat == nil || p.Location.Lon == nil:
+		if p.Location.Lat == nil {
+			errs = append(errs, csvreader.RowError{Line: line, Column: "latitude", Reason: "required field is missing"})
+		}
+		if p.Location.Lon == nil {
+			errs = append(errs, csvreader.RowError{Line: line, Column: "longitude", Reason: "required field is missing"})
+		}
+	default:
+		place.Location = types.GeoJSON{Latitude: *p.Location.Lat, Longitude: *p.Location.Lon}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return place, nil
+}
@@ -0,0 +1,45 @@
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLRejectsMissingCoordinates(t *testing.T) {
+	input := `{"id":1,"name":"SMETANA","location":{"lat":55.75,"lon":37.61}}
+{"id":2,"name":"Rodnik","location":{}}
+{"id":3,"name":"Bar","location":{"lat":55.7}}
+{"id":4,"name":"Kafe"}
+{"id":5,"name":"Zero","location":{"lat":0,"lon":0}}
+`
+	reader, err := NewReader(strings.NewReader(input), FormatJSONL, csvreader.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	places, err := ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Нулевые координаты, заданные явно, допустимы; пропущенные - нет
+	want := []*types.Place{
+		{ID: 1, Name: "SMETANA", Location: types.GeoJSON{Latitude: 55.75, Longitude: 37.61}},
+		{ID: 5, Name: "Zero"},
+	}
+	if !reflect.DeepEqual(places, want) {
+		t.Errorf("got %+v, want %+v", places, want)
+	}
+
+	wantRejected := []csvreader.RowError{
+		{Line: 2, Column: "latitude", Reason: "required field is missing"},
+		{Line: 2, Column: "longitude", Reason: "required field is missing"},
+		{Line: 3, Column: "longitude", Reason: "required field is missing"},
+		{Line: 4, Column: "location", Reason: "required field is missing"},
+	}
+	if rejected := reader.Report().Rejected; !reflect.DeepEqual(rejected, wantRejected) {
+		t.Errorf("rejected %+v, want %+v", rejected, wantRejected)
+	}
+}
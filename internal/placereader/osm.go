@@ -0,0 +1,125 @@
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// osmAmenities значения тега amenity, которые считаются местами
+var osmAmenities = map[string]bool{
+	"restaurant": true,
+	"cafe":       true,
+	"fast_food":  true,
+}
+
+// osmDecoder читает точки (node) из выгрузки OSM XML с тегом amenity=restaurant|cafe|fast_food.
+// Линии и отношения пропускаются: у них нет собственных координат.
+type osmDecoder struct {
+	dec *xml.Decoder
+}
+
+type osmNode struct {
+	ID   string `xml:"id,attr"`
+	Lat  string `xml:"lat,attr"`
+	Lon  string `xml:"lon,attr"`
+	Tags []struct {
+		Key   string `xml:"k,attr"`
+		Value string `xml:"v,attr"`
+	} `xml:"tag"`
+}
+
+func newOSMDecoder(r io.Reader) *osmDecoder {
+	return &osmDecoder{dec: xml.NewDecoder(r)}
+}
+
+func (d *osmDecoder) next() (*types.Place, int, []csvreader.RowError, error) {
+	for {
+		line, _ := d.dec.InputPos()
+		token, err := d.dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, 0, nil, io.EOF
+		}
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("decoding OSM XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "node" {
+			continue
+		}
+		var node osmNode
+		if err := d.dec.DecodeElement(&node, &start); err != nil {
+			return nil, 0, nil, fmt.Errorf("decoding OSM node on line %d: %w", line, err)
+		}
+
+		tags := make(map[string]string, len(node.Tags))
+		for _, tag := range node.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		if !osmAmenities[tags["amenity"]] {
+			continue
+		}
+		place, rejected := node.place(tags, line)
+		return place, line, rejected, nil
+	}
+}
+
+// place переводит точку в место: адрес собирается из тегов addr:*, телефон берется из phone
+// или contact:phone, остальные теги попадают в Attributes
+func (n *osmNode) place(tags map[string]string, line int) (*types.Place, []csvreader.RowError) {
+	var errs []csvreader.RowError
+	fail := func(column, format string, a ...interface{}) {
+		errs = append(errs, csvreader.RowError{Line: line, Column: column, Reason: fmt.Sprintf(format, a...)})
+	}
+
+	place := &types.Place{Name: tags["name"], Address: osmAddress(tags), Phone: tags["phone"]}
+	if place.Phone == "" {
+		place.Phone = tags["contact:phone"]
+	}
+
+	var err error
+	if place.ID, err = strconv.Atoi(n.ID); err != nil {
+		fail("id", "%q is not an integer", n.ID)
+	}
+	if place.Location.Longitude, err = strconv.ParseFloat(n.Lon, 64); err != nil {
+		fail("longitude", "%q is not a number", n.Lon)
+	}
+	if place.Location.Latitude, err = strconv.ParseFloat(n.Lat, 64); err != nil {
+		fail("latitude", "%q is not a number", n.Lat)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	for key, value := range tags {
+		switch {
+		case key == "name", key == "phone", key == "contact:phone", strings.HasPrefix(key, "addr:"):
+		default:
+			if place.Attributes == nil {
+				place.Attributes = map[string]string{}
+			}
+			place.Attributes[key] = value
+		}
+	}
+	return place, nil
+}
+
+// osmAddress адрес из addr:full или из города, улицы и номера дома
+func osmAddress(tags map[string]string) string {
+	if full := tags["addr:full"]; full != "" {
+		return full
+	}
+	var parts []string
+	for _, key := range []string{"addr:city", "addr:street", "addr:housenumber"} {
+		if value := tags[key]; value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,132 @@
+// Package placereader читает места из файлов разных форматов: CSV (пакет csvreader),
+// GeoJSON FeatureCollection, JSON Lines и OSM XML. Формат задается явно или
+// определяется по расширению файла.
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Форматы файлов с местами
+const (
+	FormatCSV     = "csv"
+	FormatGeoJSON = "geojson"
+	FormatJSONL   = "jsonl"
+	FormatOSM     = "osm"
+)
+
+// Reader читает места по одному. Next возвращает io.EOF в конце файла,
+// а в строгом режиме при отклоненных записях - *csvreader.ValidationError.
+type Reader interface {
+	Next() (*types.Place, error)
+	// Report отчет о прочитанных до сих пор записях
+	Report() csvreader.Report
+}
+
+// extensions форматы по расширению файла
+var extensions = map[string]string{
+	".csv":     FormatCSV,
+	".tsv":     FormatCSV,
+	".txt":     FormatCSV,
+	".geojson": FormatGeoJSON,
+	".json":    FormatGeoJSON,
+	".jsonl":   FormatJSONL,
+	".ndjson":  FormatJSONL,
+	".osm":     FormatOSM,
+	".xml":     FormatOSM,
+}
+
+// ParseFormat проверяет имя формата; "auto" и пустая строка означают определение по расширению
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(s); format {
+	case "", "auto":
+		return "", nil
+	case FormatCSV, FormatGeoJSON, FormatJSONL, FormatOSM:
+		return format, nil
+	case "ndjson":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("unknown data format %q (want auto, csv, geojson, jsonl or osm)", s)
+	}
+}
+
+// DetectFormat определяет формат по расширению path
+func DetectFormat(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if format, ok := extensions[ext]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("cannot detect the format of %s by its extension; set it explicitly", path)
+}
+
+// NewReader читает места формата format из r. opts.Strict действует для всех форматов,
+// остальные параметры opts - только для CSV.
+func NewReader(r io.Reader, format string, opts csvreader.Options) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return csvreader.NewReader(r, opts)
+	case FormatGeoJSON:
+		return newValidatingReader(newGeoJSONDecoder(r), opts.Strict), nil
+	case FormatJSONL:
+		return newValidatingReader(newJSONLDecoder(r), opts.Strict), nil
+	case FormatOSM:
+		return newValidatingReader(newOSMDecoder(r), opts.Strict), nil
+	default:
+		return nil, fmt.Errorf("unknown data format %q", format)
+	}
+}
+
+// File читатель открытого файла с местами; Close закрывает файл
+type File struct {
+	Reader
+	file *os.File
+}
+
+// Close закрывает файл
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// Open открывает файл path; пустой format определяется по расширению
+func Open(path, format string, opts csvreader.Options) (*File, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening places file: %w", err)
+	}
+
+	reader, err := NewReader(file, format, opts)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &File{Reader: reader, file: file}, nil
+}
+
+// ReadAll читает все места из r
+func ReadAll(r Reader) ([]*types.Place, error) {
+	var places []*types.Place
+	for {
+		place, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return places, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		places = append(places, place)
+	}
+}
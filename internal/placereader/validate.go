@@ -0,0 +1,116 @@
+package placereader
+
+import (
+	"elasticTask/internal/csvreader"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// decoder разбирает записи одного формата. place == nil вместе с непустым rejected - запись отклонена;
+// err - ошибка, после которой чтение продолжить нельзя (io.EOF в конце файла).
+type decoder interface {
+	next() (place *types.Place, line int, rejected []csvreader.RowError, err error)
+}
+
+// validatingReader проверяет места из decoder так же, как csvreader проверяет строки CSV
+type validatingReader struct {
+	dec    decoder
+	strict bool
+	report csvreader.Report
+	seen   map[int]int
+}
+
+func newValidatingReader(dec decoder, strict bool) *validatingReader {
+	return &validatingReader{dec: dec, strict: strict, seen: map[int]int{}}
+}
+
+// Next возвращает следующее корректное место, пропуская отклоненные записи
+func (r *validatingReader) Next() (*types.Place, error) {
+	for {
+		place, line, rejected, err := r.dec.next()
+		if errors.Is(err, io.EOF) {
+			if r.strict && len(r.report.Rejected) > 0 {
+				return nil, &csvreader.ValidationError{Report: r.report}
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r.report.Rows++
+		if place != nil {
+			rejected = append(rejected, validatePlace(place, line)...)
+			if first, ok := r.seen[place.ID]; ok && len(rejected) == 0 {
+				rejected = append(rejected, csvreader.RowError{Line: line, Column: "id",
+					Reason: fmt.Sprintf("duplicate ID %d (first seen on line %d)", place.ID, first)})
+			}
+		}
+		if len(rejected) > 0 || place == nil {
+			r.report.Rejected = append(r.report.Rejected, rejected...)
+			continue
+		}
+
+		r.seen[place.ID] = line
+		r.report.Accepted++
+		return place, nil
+	}
+}
+
+// Report отчет о прочитанных до сих пор записях
+func (r *validatingReader) Report() csvreader.Report {
+	return r.report
+}
+
+// validatePlace проверяет обязательные поля и диапазоны координат
+func validatePlace(place *types.Place, line int) []csvreader.RowError {
+	var errs []csvreader.RowError
+	fail := func(column, format string, a ...interface{}) {
+		errs = append(errs, csvreader.RowError{Line: line, Column: column, Reason: fmt.Sprintf(format, a...)})
+	}
+
+	if place.ID < 0 {
+		fail("id", "negative ID %d", place.ID)
+	}
+	if strings.TrimSpace(place.Name) == "" {
+		fail("name", "required field is empty")
+	}
+	if lon := place.Location.Longitude; math.IsNaN(lon) || lon < -180 || lon > 180 {
+		fail("longitude", "%v is out of range [-180, 180]", lon)
+	}
+	if lat := place.Location.Latitude; math.IsNaN(lat) || lat < -90 || lat > 90 {
+		fail("latitude", "%v is out of range [-90, 90]", lat)
+	}
+	return errs
+}
+
+// parseID разбирает ID, заданный числом или строкой с числом
+func parseID(raw json.RawMessage) (int, error) {
+	var id json.Number
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return 0, fmt.Errorf("%s is not a number", raw)
+	}
+	n, err := strconv.Atoi(id.String())
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an integer", raw)
+	}
+	return n, nil
+}
+
+// attributeValue строковое значение дополнительного свойства: строка как есть, остальное - JSON
+func attributeValue(raw json.RawMessage) string {
+	s := strings.TrimSpace(string(raw))
+	if len(s) >= 2 && s[0] == '"' {
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			return str
+		}
+	}
+	return s
+}
@@ -6,17 +6,16 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-var secretKey = []byte("secretkey")
-
-// GenerateToken генерирует JWT-токен на основе переданного имени пользователя.
-func GenerateToken() (string, error) {
+// GenerateToken генерирует JWT-токен, подписанный ключом secretKey.
+func GenerateToken(secretKey []byte) (string, error) {
 
 	t := jwt.New(jwt.SigningMethodHS256)
 	s, er := t.SignedString(secretKey)
 	return s, er
 }
 
-func VerifyToken(tokenString string) (jwt.MapClaims, error) {
+// VerifyToken проверяет подпись токена ключом secretKey и возвращает его claims.
+func VerifyToken(tokenString string, secretKey []byte) (jwt.MapClaims, error) {
 
 	// Проверка и удаление префикса "Bearer "
 	if strings.HasPrefix(tokenString, "Bearer ") {
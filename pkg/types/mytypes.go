@@ -1,11 +1,15 @@
 package types
 
+import "fmt"
+
 type Place struct {
 	ID       int     `json:"id"`
 	Name     string  `json:"name"`
 	Address  string  `json:"address"`
 	Phone    string  `json:"phone"`
 	Location GeoJSON `json:"location"`
+	// Attributes дополнительные колонки исходного файла, например часы работы или кухня
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 type GeoJSON struct {
@@ -14,18 +18,73 @@ type GeoJSON struct {
 }
 
 type PageData struct {
-	Total     int
-	Places    []Place
-	HasPrev   bool
-	PrevPage  int
-	HasNext   bool
-	NextPage  int
-	LastPage  int
-	FirstPage int
+	Total      int
+	Places     []Place
+	HasPrev    bool
+	PrevPage   int
+	HasNext    bool
+	NextPage   int
+	LastPage   int
+	FirstPage  int
+	PageSize   int
+	SortBy     string   `json:",omitempty"`
+	Origin     *GeoJSON `json:",omitempty"`
+	Query      string   `json:",omitempty"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 type Recommendation struct {
-	Name   string  `json:"name"`
-	Places []Place `json:"places"`
+	Name   string             `json:"name"`
+	Places []RecommendedPlace `json:"places"`
+	Total  int                `json:"total"`
+	Radius string             `json:"radius,omitempty"`
+}
+
+// RecommendedPlace место вместе с расстоянием до него в метрах
+type RecommendedPlace struct {
+	Place
+	Distance float64 `json:"distance"`
+}
+
+// DistanceText возвращает расстояние в читаемом виде: "350 m" или "1.2 km"
+func (p RecommendedPlace) DistanceText() string {
+	if p.Distance < 1000 {
+		return fmt.Sprintf("%.0f m", p.Distance)
+	}
+	return fmt.Sprintf("%.1f km", p.Distance/1000)
+}
+
+type Suggestion struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Address  string  `json:"address"`
+	Location GeoJSON `json:"location"`
+}
+
+// BoundingBoxData места внутри прямоугольника карты и их общее количество
+type BoundingBoxData struct {
 	Total  int     `json:"total"`
+	Places []Place `json:"places"`
+}
+
+// BoundingBox прямоугольник на карте, заданный северо-западным и юго-восточным углами
+type BoundingBox struct {
+	TopLeft     GeoJSON `json:"top_left"`
+	BottomRight GeoJSON `json:"bottom_right"`
+}
+
+// Cluster ячейка geohash-сетки с количеством мест и их центром масс
+type Cluster struct {
+	Geohash  string  `json:"geohash"`
+	Count    int     `json:"count"`
+	Centroid GeoJSON `json:"centroid"`
+}
+
+type ClusterData struct {
+	Total     int       `json:"total"`
+	Precision int       `json:"precision"`
+	Clusters  []Cluster `json:"clusters"`
+	// Truncated - хранилище вернуло не все ячейки: сумма Count меньше Total.
+	// Стоит уменьшить precision или область карты.
+	Truncated bool `json:"truncated"`
 }
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// errorResponse тело ответа с ошибкой
+type errorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// statusForError сопоставляет класс ошибки хранилища HTTP-статусу ответа
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, db.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError отвечает клиенту JSON с ошибкой хранилища и подходящим статусом.
+// Подробности ошибки пишутся в лог, клиент получает только текст статуса.
+func writeError(w http.ResponseWriter, err error) {
+	// Клиент уже отключился, отвечать некому
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	status := statusForError(err)
+	log.Printf("Store error (%d): %s", status, err)
+
+	message := http.StatusText(status)
+	if status == http.StatusBadRequest {
+		message = err.Error()
+	}
+
+	jsonData, _ := json.Marshal(errorResponse{Error: message, Status: status})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"context"
+	"elasticTask/internal/db"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newElasticsearchStore создает хранилище Elasticsearch поверх фейкового сервера с обработчиком handler
+func newElasticsearchStore(t *testing.T, queryTimeout time.Duration, handler http.HandlerFunc) *db.ElasticsearchStore {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	store, err := db.NewElasticsearchStore(db.ClientConfig{Addresses: []string{server.URL}}, "places", queryTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestWriteErrorFromElasticsearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		esStatus   int
+		esBody     string
+		slow       bool
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "bad request",
+			esStatus:   400,
+			esBody:     `{"error":{"type":"parse_exception","reason":"failed to parse query"},"status":400}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "list places: bad request: [400] parse_exception: failed to parse query",
+		},
+		{
+			name:       "not found",
+			esStatus:   404,
+			esBody:     `{"error":{"type":"index_not_found_exception","reason":"no such index [places]"},"status":404}`,
+			wantStatus: http.StatusNotFound,
+			wantError:  "Not Found",
+		},
+		{
+			name:       "too many requests",
+			esStatus:   429,
+			esBody:     `{"error":{"type":"es_rejected_execution_exception","reason":"rejected execution"},"status":429}`,
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  "Service Unavailable",
+		},
+		{
+			name:       "unavailable",
+			esStatus:   503,
+			esBody:     `{"error":"cluster is recovering"}`,
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  "Service Unavailable",
+		},
+		{
+			name:       "gateway timeout",
+			esStatus:   504,
+			esBody:     `upstream timed out`,
+			wantStatus: http.StatusGatewayTimeout,
+			wantError:  "Gateway Timeout",
+		},
+		{
+			name:       "slow response",
+			slow:       true,
+			wantStatus: http.StatusGatewayTimeout,
+			wantError:  "Gateway Timeout",
+		},
+		{
+			name:       "unclassified",
+			esStatus:   500,
+			esBody:     `{"error":{"type":"illegal_state_exception","reason":"boom"},"status":500}`,
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newElasticsearchStore(t, 50*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				if tt.slow {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+					return
+				}
+				w.WriteHeader(tt.esStatus)
+				io.WriteString(w, tt.esBody)
+			})
+
+			rec := httptest.NewRecorder()
+			JsonHandler(store, DefaultOptions())(rec, httptest.NewRequest(http.MethodGet, "/api/places?page=1", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type %q, want application/json", ct)
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q is not JSON: %v", rec.Body, err)
+			}
+			if body.Status != tt.wantStatus || body.Error != tt.wantError {
+				t.Errorf("body %+v, want status %d error %q", body, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestWriteErrorCanceled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, fmt.Errorf("list places: %w", context.Canceled))
+
+	// Клиент отключился: ответ не пишется, статус остается по умолчанию
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("response written for a canceled request: %d %q", rec.Code, rec.Body)
+	}
+}
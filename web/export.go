@@ -0,0 +1,73 @@
+package web
+
+import (
+	"elasticTask/internal/db"
+	"elasticTask/internal/export"
+	"elasticTask/pkg/types"
+	"io"
+	"log"
+	"net/http"
+)
+
+// exportBatchSize количество мест, запрашиваемых у Elasticsearch за один раз при выгрузке
+const exportBatchSize = 1000
+
+// ExportHandler потоково выгружает все места индекса в формате ndjson, csv или geojson
+func ExportHandler(store db.PlaceScanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = export.FormatNDJSON
+		}
+
+		if _, err := export.NewWriter(format, io.Discard, nil); err != nil {
+			http.Error(w, "Invalid 'format' value: '"+format+"'", http.StatusBadRequest)
+			return
+		}
+
+		// Колонки атрибутов CSV нужны до заголовка, поэтому для CSV места обходятся дважды
+		var (
+			attributes []string
+			err        error
+		)
+		if format == export.FormatCSV {
+			if attributes, err = export.AttributeKeys(r.Context(), store, exportBatchSize); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		writer, _ := export.NewWriter(format, w, attributes)
+
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="places.`+export.FileExtension(format)+`"`)
+
+		flusher, _ := w.(http.Flusher)
+		written := false
+		err = store.ScanPlaces(r.Context(), exportBatchSize, func(places []types.Place) error {
+			written = written || len(places) > 0
+			for _, place := range places {
+				if err := writer.Write(place); err != nil {
+					return err
+				}
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = writer.Close()
+		}
+
+		if err != nil && !written {
+			w.Header().Del("Content-Disposition")
+			writeError(w, err)
+			return
+		}
+
+		// Заголовки уже отправлены, поэтому остается только оборвать выгрузку и записать ошибку в лог
+		if err != nil {
+			log.Printf("Export failed: %s", err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"elasticTask/internal/db"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseGeoPoint разбирает точку в формате "lat,lon"
+func parseGeoPoint(s string) (types.GeoJSON, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return types.GeoJSON{}, fmt.Errorf("expected 'lat,lon', got %q", s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return types.GeoJSON{}, fmt.Errorf("invalid latitude in %q", s)
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return types.GeoJSON{}, fmt.Errorf("invalid longitude in %q", s)
+	}
+
+	return types.GeoJSON{Latitude: lat, Longitude: lon}, nil
+}
+
+// JsonBoundingBoxHandler возвращает места внутри прямоугольника tl (северо-запад) - br (юго-восток)
+func JsonBoundingBoxHandler(store db.GeoStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tlStr := r.URL.Query().Get("tl")
+		topLeft, err := parseGeoPoint(tlStr)
+		if err != nil {
+			http.Error(w, "Invalid 'tl' value: '"+tlStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		brStr := r.URL.Query().Get("br")
+		bottomRight, err := parseGeoPoint(brStr)
+		if err != nil {
+			http.Error(w, "Invalid 'br' value: '"+brStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		if topLeft.Latitude < bottomRight.Latitude {
+			http.Error(w, "Invalid bounding box: 'tl' must be north of 'br'", http.StatusBadRequest)
+			return
+		}
+
+		limit := 100
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 0 || limit > 1000 {
+				http.Error(w, "Invalid 'limit' value: '"+limitStr+"'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		places, total, err := store.GetPlacesInBoundingBox(r.Context(), topLeft, bottomRight, limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		data := types.BoundingBoxData{
+			Total:  total,
+			Places: places,
+		}
+
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonData)
+	}
+}
+
+// parseBoundingBox разбирает прямоугольник в формате "tl_lat,tl_lon,br_lat,br_lon"
+func parseBoundingBox(s string) (types.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return types.BoundingBox{}, fmt.Errorf("expected 'tl_lat,tl_lon,br_lat,br_lon', got %q", s)
+	}
+
+	topLeft, err := parseGeoPoint(parts[0] + "," + parts[1])
+	if err != nil {
+		return types.BoundingBox{}, err
+	}
+
+	bottomRight, err := parseGeoPoint(parts[2] + "," + parts[3])
+	if err != nil {
+		return types.BoundingBox{}, err
+	}
+
+	if topLeft.Latitude < bottomRight.Latitude {
+		return types.BoundingBox{}, fmt.Errorf("top left corner must be north of bottom right in %q", s)
+	}
+
+	return types.BoundingBox{TopLeft: topLeft, BottomRight: bottomRight}, nil
+}
+
+// JsonClustersHandler возвращает места, сгруппированные в ячейки geohash-сетки
+func JsonClustersHandler(store db.GeoStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		precision := 5
+		if precisionStr := r.URL.Query().Get("precision"); precisionStr != "" {
+			var err error
+			precision, err = strconv.Atoi(precisionStr)
+			if err != nil || precision < 1 || precision > 12 {
+				http.Error(w, "Invalid 'precision' value: '"+precisionStr+"'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var box *types.BoundingBox
+		if bboxStr := r.URL.Query().Get("bbox"); bboxStr != "" {
+			parsed, err := parseBoundingBox(bboxStr)
+			if err != nil {
+				http.Error(w, "Invalid 'bbox' value: '"+bboxStr+"'", http.StatusBadRequest)
+				return
+			}
+			box = &parsed
+		}
+
+		clusters, total, err := store.GetClusters(r.Context(), precision, box)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Хранилище может вернуть не все ячейки; клиент узнает об этом по сумме мест в них
+		inClusters := 0
+		for _, cluster := range clusters {
+			inClusters += cluster.Count
+		}
+
+		data := types.ClusterData{
+			Total:     total,
+			Precision: precision,
+			Clusters:  clusters,
+			Truncated: inClusters < total,
+		}
+
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonData)
+	}
+}
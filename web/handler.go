@@ -6,13 +6,17 @@ import (
 	"elasticTask/internal/utils"
 	"elasticTask/pkg/types"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
-func HtmlHandler(es *db.ElasticsearchStore) http.HandlerFunc {
+func HtmlHandler(store db.Store, options Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		pageStr := r.URL.Query().Get("page")
 		page, err := strconv.Atoi(pageStr)
@@ -21,16 +25,22 @@ func HtmlHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			return
 		}
 
-		limit := 10
+		opts, err := parseListOptions(r, options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := opts.PageSize
 		offset := (page - 1) * limit
 
-		places, total, err := es.GetPlaces(limit, offset)
+		places, total, err := store.GetPlaces(r.Context(), limit, offset, opts.Sort)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
-		lastPage := total / limit
+		lastPage := lastPageFor(total, limit)
 		hasPrev := page > 1
 		hasNext := page < lastPage
 		first := 1
@@ -49,8 +59,11 @@ func HtmlHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			NextPage:  page + 1,
 			LastPage:  lastPage,
 			FirstPage: first,
+			PageSize:  limit,
+			SortBy:    opts.SortParam,
+			Origin:    opts.Sort.Origin,
 		}
-		tmpl, err := template.ParseFiles("web/template_style.html")
+		tmpl, err := options.template("template_style.html")
 		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			//fmt.Println("hgfdcsfghjuhgsa")
@@ -65,8 +78,14 @@ func HtmlHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 	}
 }
 
-func JsonHandler(es *db.ElasticsearchStore) http.HandlerFunc {
+func JsonHandler(store db.Store, options Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Параметр cursor (в том числе пустой) включает постраничный обход по курсору
+		if r.URL.Query().Has("cursor") {
+			jsonCursorPage(w, r, store, options)
+			return
+		}
+
 		pageStr := r.URL.Query().Get("page")
 		page, err := strconv.Atoi(pageStr)
 		if err != nil || page < 1 {
@@ -74,16 +93,22 @@ func JsonHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			return
 		}
 
-		limit := 10
+		opts, err := parseListOptions(r, options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := opts.PageSize
 		offset := (page - 1) * limit
 
-		places, total, err := es.GetPlaces(limit, offset)
+		places, total, err := store.GetPlaces(r.Context(), limit, offset, opts.Sort)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
-		lastPage := total / limit
+		lastPage := lastPageFor(total, limit)
 		hasPrev := page > 1
 		hasNext := page < lastPage
 
@@ -100,6 +125,9 @@ func JsonHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			HasNext:  hasNext,
 			NextPage: page + 1,
 			LastPage: lastPage,
+			PageSize: limit,
+			SortBy:   opts.SortParam,
+			Origin:   opts.Sort.Origin,
 		}
 
 		// Преобразуем данные в формат JSON
@@ -117,7 +145,120 @@ func JsonHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 	}
 }
 
-func HtmlRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
+// Options настройки обработчиков, не зависящие от хранилища
+type Options struct {
+	// TemplateDir директория HTML-шаблонов
+	TemplateDir string
+	// PageSize количество мест на странице, если page_size не передан
+	PageSize int
+	// MaxPageSize верхняя граница page_size
+	MaxPageSize int
+}
+
+// DefaultOptions шаблоны из web/, 10 мест на странице, не больше 100
+func DefaultOptions() Options {
+	return Options{TemplateDir: "web", PageSize: 10, MaxPageSize: 100}
+}
+
+// template разбирает шаблон name из TemplateDir
+func (o Options) template(name string) (*template.Template, error) {
+	return template.ParseFiles(filepath.Join(o.TemplateDir, name))
+}
+
+// listOptions параметры списка мест: размер страницы и сортировка
+type listOptions struct {
+	PageSize  int
+	Sort      db.SortOption
+	SortParam string
+}
+
+// parsePageSize читает page_size из запроса с проверкой границ
+func parsePageSize(r *http.Request, opts Options) (int, error) {
+	sizeStr := r.URL.Query().Get("page_size")
+	if sizeStr == "" {
+		return opts.PageSize, nil
+	}
+
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size < 1 || size > opts.MaxPageSize {
+		return 0, fmt.Errorf("Invalid 'page_size' value: '%s' (1..%d)", sizeStr, opts.MaxPageSize)
+	}
+	return size, nil
+}
+
+// parseListOptions читает page_size и sort=name|id|-name|-id|distance; для distance нужны lat и lon
+func parseListOptions(r *http.Request, options Options) (listOptions, error) {
+	size, err := parsePageSize(r, options)
+	if err != nil {
+		return listOptions{}, err
+	}
+	opts := listOptions{PageSize: size}
+
+	sortStr := r.URL.Query().Get("sort")
+	switch sortStr {
+	case "":
+	case "name", "-name", "id", "-id":
+		opts.Sort = db.SortOption{
+			Field: strings.TrimPrefix(sortStr, "-"),
+			Desc:  strings.HasPrefix(sortStr, "-"),
+		}
+	case "distance":
+		lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+		if err != nil {
+			return listOptions{}, errors.New("Invalid 'lat' value: sort=distance requires 'lat' and 'lon'")
+		}
+
+		lon, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+		if err != nil {
+			return listOptions{}, errors.New("Invalid 'lon' value: sort=distance requires 'lat' and 'lon'")
+		}
+
+		opts.Sort = db.SortOption{
+			Field:  db.SortByDistance,
+			Origin: &types.GeoJSON{Latitude: lat, Longitude: lon},
+		}
+	default:
+		return listOptions{}, fmt.Errorf("Invalid 'sort' value: '%s'", sortStr)
+	}
+
+	opts.SortParam = sortStr
+	return opts, nil
+}
+
+// jsonCursorPage отдает страницу мест по курсору из параметра cursor
+func jsonCursorPage(w http.ResponseWriter, r *http.Request, store db.Store, options Options) {
+	limit, err := parsePageSize(r, options)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cursor := r.URL.Query().Get("cursor")
+
+	places, total, next, err := store.GetPlacesAfter(r.Context(), limit, cursor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	data := types.PageData{
+		Total:      total,
+		Places:     places,
+		HasNext:    next != "",
+		PageSize:   limit,
+		NextCursor: next,
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
+
+func HtmlRecommendHandler(store db.Store, options Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Получение параметров из URL
 		latStr := r.URL.Query().Get("lat")
@@ -136,20 +277,28 @@ func HtmlRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			return
 		}
 
+		radiusStr := r.URL.Query().Get("radius")
+		radius, err := parseRadius(radiusStr)
+		if err != nil {
+			http.Error(w, "Invalid 'radius' value: '"+radiusStr+"'", http.StatusBadRequest)
+			return
+		}
+
 		limit := 3
 		// Выполнение запроса Elasticsearch
-		places, total, err := es.GetRecommendPlaces(limit, lat, lon)
+		places, total, err := store.GetRecommendPlaces(r.Context(), limit, lat, lon, radius)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
 		data := types.Recommendation{
 			Places: places,
 			Total:  total,
+			Radius: radius,
 		}
 
-		tmpl, err := template.ParseFiles("web/rec_template_style.html")
+		tmpl, err := options.template("rec_template_style.html")
 		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			//fmt.Println("hgfdcsfghjuhgsa")
@@ -164,7 +313,7 @@ func HtmlRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 	}
 }
 
-func JsonRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
+func JsonRecommendHandler(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Получение параметров из URL
 		latStr := r.URL.Query().Get("lat")
@@ -183,17 +332,25 @@ func JsonRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 			return
 		}
 
+		radiusStr := r.URL.Query().Get("radius")
+		radius, err := parseRadius(radiusStr)
+		if err != nil {
+			http.Error(w, "Invalid 'radius' value: '"+radiusStr+"'", http.StatusBadRequest)
+			return
+		}
+
 		limit := 3
 		// Выполнение запроса Elasticsearch
-		places, total, err := es.GetRecommendPlaces(limit, lat, lon)
+		places, total, err := store.GetRecommendPlaces(r.Context(), limit, lat, lon, radius)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
 		data := types.Recommendation{
 			Places: places,
 			Total:  total,
+			Radius: radius,
 		}
 
 		// Преобразовываем данные в формат JSON
@@ -211,12 +368,12 @@ func JsonRecommendHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 	}
 }
 
-// Middleware для проверки токена перед доступом к защищенному ресурсу
-func AuthMiddleware(next http.Handler) http.Handler {
+// Middleware для проверки токена, подписанного secret, перед доступом к защищенному ресурсу
+func AuthMiddleware(secret []byte, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := r.Header.Get("Authorization")
 		// Проверка токена
-		claims, err := utils.VerifyToken(tokenString)
+		claims, err := utils.VerifyToken(tokenString, secret)
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -229,12 +386,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// TokenHandler генерирует токен и возвращает его
-func TokenHandler(es *db.ElasticsearchStore) http.HandlerFunc {
+// TokenHandler генерирует токен, подписанный secret, и возвращает его
+func TokenHandler(store db.Store, secret []byte) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		// Генерация токена
-		token, err := utils.GenerateToken()
+		token, err := utils.GenerateToken(secret)
 		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -254,3 +411,234 @@ func TokenHandler(es *db.ElasticsearchStore) http.HandlerFunc {
 		w.Write(jsonResponse)
 	}
 }
+
+// radiusPattern число с необязательной дробной частью и единицами расстояния Elasticsearch
+var radiusPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)(mi|miles|yd|yards|ft|feet|in|inch|km|kilometers|m|meters|cm|centimeters|mm|millimeters|nmi|NM)?$`)
+
+// parseRadius проверяет радиус поиска и приводит его к формату Elasticsearch.
+// Число без единиц измерения считается метрами, пустая строка означает отсутствие ограничения.
+func parseRadius(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	m := radiusPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", fmt.Errorf("invalid radius %q", s)
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || value <= 0 {
+		return "", fmt.Errorf("invalid radius %q", s)
+	}
+
+	unit := m[2]
+	if unit == "" {
+		unit = "m"
+	}
+	return m[1] + unit, nil
+}
+
+// lastPageFor возвращает номер последней страницы для total мест по limit на странице
+func lastPageFor(total, limit int) int {
+	if total <= 0 {
+		return 1
+	}
+	return (total + limit - 1) / limit
+}
+
+func HtmlSearchHandler(store db.Store, options Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			http.Error(w, "Missing 'q' value", http.StatusBadRequest)
+			return
+		}
+
+		pageStr := r.URL.Query().Get("page")
+		if pageStr == "" {
+			pageStr = "1"
+		}
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			http.Error(w, "Invalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		limit := options.PageSize
+		offset := (page - 1) * limit
+
+		places, total, err := store.Search(r.Context(), query, limit, offset)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		lastPage := lastPageFor(total, limit)
+		if page > lastPage {
+			http.Error(w, "Invalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		data := types.PageData{
+			Total:     total,
+			Places:    places,
+			HasPrev:   page > 1,
+			PrevPage:  page - 1,
+			HasNext:   page < lastPage,
+			NextPage:  page + 1,
+			LastPage:  lastPage,
+			FirstPage: 1,
+			Query:     query,
+		}
+
+		tmpl, err := options.template("search_template_style.html")
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		err = tmpl.Execute(w, data)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func JsonSearchHandler(store db.Store, options Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			http.Error(w, "Missing 'q' value", http.StatusBadRequest)
+			return
+		}
+
+		pageStr := r.URL.Query().Get("page")
+		if pageStr == "" {
+			pageStr = "1"
+		}
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			http.Error(w, "Invalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		limit := options.PageSize
+		offset := (page - 1) * limit
+
+		places, total, err := store.Search(r.Context(), query, limit, offset)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		lastPage := lastPageFor(total, limit)
+		if page > lastPage {
+			http.Error(w, "Invalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		data := types.PageData{
+			Total:    total,
+			Places:   places,
+			HasPrev:  page > 1,
+			PrevPage: page - 1,
+			HasNext:  page < lastPage,
+			NextPage: page + 1,
+			LastPage: lastPage,
+			Query:    query,
+		}
+
+		// Преобразуем данные в формат JSON
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonData)
+	}
+}
+
+func JsonSuggestHandler(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "" {
+			http.Error(w, "Missing 'prefix' value", http.StatusBadRequest)
+			return
+		}
+
+		limit := 10
+		if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+			size, err := strconv.Atoi(sizeStr)
+			if err != nil || size < 1 || size > 50 {
+				http.Error(w, "Invalid 'size' value: '"+sizeStr+"'", http.StatusBadRequest)
+				return
+			}
+			limit = size
+		}
+
+		// Координаты необязательны, но задаются только парой
+		var origin *types.GeoJSON
+		latStr := r.URL.Query().Get("lat")
+		lonStr := r.URL.Query().Get("lon")
+		if latStr != "" || lonStr != "" {
+			lat, err := strconv.ParseFloat(latStr, 64)
+			if err != nil {
+				http.Error(w, "Invalid 'lat' value", http.StatusBadRequest)
+				return
+			}
+
+			lon, err := strconv.ParseFloat(lonStr, 64)
+			if err != nil {
+				http.Error(w, "Invalid 'lon' value", http.StatusBadRequest)
+				return
+			}
+			origin = &types.GeoJSON{Latitude: lat, Longitude: lon}
+		}
+
+		suggestions, err := store.Suggest(r.Context(), prefix, limit, origin)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		jsonData, err := json.Marshal(suggestions)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonData)
+	}
+}
+
+// JsonPlaceHandler возвращает одно место по параметру id
+func JsonPlaceHandler(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.URL.Query().Get("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid 'id' value: '"+idStr+"'", http.StatusBadRequest)
+			return
+		}
+
+		place, err := store.GetPlace(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		jsonData, err := json.Marshal(place)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonData)
+	}
+}
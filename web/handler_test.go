@@ -0,0 +1,456 @@
+package web
+
+import (
+	"bufio"
+	"elasticTask/internal/db"
+	"elasticTask/internal/db/fakestore"
+	"elasticTask/pkg/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testOptions шаблоны лежат рядом с тестами, в директории пакета
+var testOptions = Options{TemplateDir: ".", PageSize: 10, MaxPageSize: 100}
+
+func newFakeStore(t *testing.T) *fakestore.Store {
+	t.Helper()
+
+	store, err := fakestore.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Places) != 20 {
+		t.Fatalf("fixture has %d places, want 20", len(store.Places))
+	}
+	return store
+}
+
+// get выполняет GET target обработчиком handler
+func get(handler http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+// decodeJSON проверяет статус 200 и разбирает тело ответа в v
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+// placeIDs идентификаторы мест в порядке ответа
+func placeIDs(places []types.Place) []int {
+	ids := make([]int, 0, len(places))
+	for _, place := range places {
+		ids = append(ids, place.ID)
+	}
+	return ids
+}
+
+func TestJsonHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonHandler(store, testOptions)
+
+	var data types.PageData
+	decodeJSON(t, get(handler, "/api/places?page=2"), &data)
+	if data.Total != 20 || len(data.Places) != 10 || data.Places[0].ID != 10 {
+		t.Errorf("page 2: total %d, ids %v", data.Total, placeIDs(data.Places))
+	}
+	if !data.HasPrev || data.HasNext || data.LastPage != 2 {
+		t.Errorf("page 2: HasPrev %v HasNext %v LastPage %d", data.HasPrev, data.HasNext, data.LastPage)
+	}
+
+	data = types.PageData{}
+	decodeJSON(t, get(handler, "/api/places?page=1&page_size=3&sort=-id"), &data)
+	if got := fmt.Sprint(placeIDs(data.Places)); got != "[19 18 17]" || data.LastPage != 7 || data.SortBy != "-id" {
+		t.Errorf("sort=-id: ids %s, last page %d, sort %q", got, data.LastPage, data.SortBy)
+	}
+
+	data = types.PageData{}
+	decodeJSON(t, get(handler, "/api/places?page=1&page_size=2&sort=distance&lat=55.7522&lon=37.6156"), &data)
+	if got := fmt.Sprint(placeIDs(data.Places)); got != "[15 5]" || data.Origin == nil {
+		t.Errorf("sort=distance: ids %s, origin %v", got, data.Origin)
+	}
+
+	for _, target := range []string{
+		"/api/places",
+		"/api/places?page=0",
+		"/api/places?page=3",
+		"/api/places?page=1&page_size=101",
+		"/api/places?page=1&sort=rating",
+		"/api/places?page=1&sort=distance&lat=55.7",
+	} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonHandlerCursor(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonHandler(store, testOptions)
+
+	var ids []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor pagination does not end")
+		}
+
+		var data types.PageData
+		decodeJSON(t, get(handler, "/api/places?page_size=8&cursor="+cursor), &data)
+		if data.Total != 20 || data.HasNext != (data.NextCursor != "") {
+			t.Fatalf("total %d, HasNext %v, next cursor %q", data.Total, data.HasNext, data.NextCursor)
+		}
+		ids = append(ids, placeIDs(data.Places)...)
+		if data.NextCursor == "" {
+			break
+		}
+		cursor = data.NextCursor
+	}
+	if len(ids) != 20 || ids[0] != 0 || ids[19] != 19 {
+		t.Errorf("cursor pages returned ids %v", ids)
+	}
+
+	if rec := get(handler, "/api/places?cursor=garbage"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor: status %d, want 400", rec.Code)
+	}
+	if calls := store.Calls(); calls[len(calls)-1] != "GetPlacesAfter" {
+		t.Errorf("cursor request called %v", calls)
+	}
+}
+
+func TestHtmlHandlers(t *testing.T) {
+	store := newFakeStore(t)
+
+	tests := []struct {
+		target  string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"/web/places?page=1", HtmlHandler(store, testOptions), "Rodnik"},
+		{"/web/recommend?lat=55.7522&lon=37.6156&radius=2km", HtmlRecommendHandler(store, testOptions), "Brusnika"},
+		{"/web/search?q=aviamotornaja", HtmlSearchHandler(store, testOptions), "Bufet MTUSI"},
+	}
+	for _, tt := range tests {
+		rec := get(tt.handler, tt.target)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status %d, body does not mention %q", tt.target, rec.Code, tt.want)
+		}
+	}
+
+	// Без шаблонов страница не рендерится
+	missing := Options{TemplateDir: "missing", PageSize: 10, MaxPageSize: 100}
+	if rec := get(HtmlHandler(store, missing), "/web/places?page=1"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("missing template: status %d, want 500", rec.Code)
+	}
+}
+
+func TestJsonRecommendHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonRecommendHandler(store)
+
+	var data types.Recommendation
+	decodeJSON(t, get(handler, "/api/recommend?lat=55.7522&lon=37.6156"), &data)
+	if len(data.Places) != 3 || data.Total != 20 || data.Places[0].ID != 15 {
+		t.Fatalf("got %d places of %d, first %+v", len(data.Places), data.Total, data.Places)
+	}
+	for i := 1; i < len(data.Places); i++ {
+		if data.Places[i].Distance < data.Places[i-1].Distance {
+			t.Errorf("distances are not ascending: %v then %v", data.Places[i-1].Distance, data.Places[i].Distance)
+		}
+	}
+
+	data = types.Recommendation{}
+	decodeJSON(t, get(handler, "/api/recommend?lat=55.7522&lon=37.6156&radius=1.2km"), &data)
+	if data.Radius != "1.2km" || data.Total != 1 || len(data.Places) != 1 || data.Places[0].Distance > 1200 {
+		t.Errorf("radius 1.2km: radius %q, total %d, places %+v", data.Radius, data.Total, data.Places)
+	}
+
+	for _, target := range []string{
+		"/api/recommend?lon=37.6",
+		"/api/recommend?lat=55.7&lon=east",
+		"/api/recommend?lat=55.7&lon=37.6&radius=-5km",
+		"/api/recommend?lat=55.7&lon=37.6&radius=2parsecs",
+	} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonSearchHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonSearchHandler(store, Options{TemplateDir: ".", PageSize: 3, MaxPageSize: 100})
+
+	var data types.PageData
+	decodeJSON(t, get(handler, "/api/search?q=Aviamotornaja"), &data)
+	if data.Total != 4 || len(data.Places) != 3 || !data.HasNext || data.Query != "Aviamotornaja" {
+		t.Errorf("page 1: total %d, ids %v, HasNext %v", data.Total, placeIDs(data.Places), data.HasNext)
+	}
+
+	data = types.PageData{}
+	decodeJSON(t, get(handler, "/api/search?q=Aviamotornaja&page=2"), &data)
+	if got := fmt.Sprint(placeIDs(data.Places)); got != "[9]" || data.HasNext {
+		t.Errorf("page 2: ids %s, HasNext %v", got, data.HasNext)
+	}
+
+	for _, target := range []string{"/api/search", "/api/search?q=kafe&page=x", "/api/search?q=kafe&page=5"} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonSuggestHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonSuggestHandler(store)
+
+	var suggestions []types.Suggestion
+	decodeJSON(t, get(handler, "/api/suggest?prefix=kafe"), &suggestions)
+	if len(suggestions) != 3 || suggestions[0].Name != "Kafe «Akademija»" {
+		t.Errorf("prefix kafe: %+v", suggestions)
+	}
+
+	suggestions = nil
+	decodeJSON(t, get(handler, "/api/suggest?prefix=kafe&size=1&lat=55.7&lon=37.6"), &suggestions)
+	if len(suggestions) != 1 {
+		t.Errorf("size=1: got %d suggestions", len(suggestions))
+	}
+
+	for _, target := range []string{
+		"/api/suggest",
+		"/api/suggest?prefix=kafe&size=0",
+		"/api/suggest?prefix=kafe&size=51",
+		"/api/suggest?prefix=kafe&lat=55.7",
+	} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonPlaceHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonPlaceHandler(store)
+
+	var place types.Place
+	decodeJSON(t, get(handler, "/api/place?id=1"), &place)
+	if place.ID != 1 || place.Name != "Rodnik" || place.Location.Latitude == 0 {
+		t.Errorf("id=1: %+v", place)
+	}
+
+	if rec := get(handler, "/api/place?id=999"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id: status %d, want 404", rec.Code)
+	}
+	for _, target := range []string{"/api/place", "/api/place?id=-1", "/api/place?id=one"} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonBoundingBoxHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonBoundingBoxHandler(store)
+
+	var data types.BoundingBoxData
+	decodeJSON(t, get(handler, "/api/places/bbox?tl=55.76,37.70&br=55.74,37.73"), &data)
+	if got := fmt.Sprint(placeIDs(data.Places)); got != "[6 7 8 9]" || data.Total != 4 {
+		t.Errorf("bbox: total %d, ids %s", data.Total, got)
+	}
+
+	data = types.BoundingBoxData{}
+	decodeJSON(t, get(handler, "/api/places/bbox?tl=55.76,37.70&br=55.74,37.73&limit=1"), &data)
+	if len(data.Places) != 1 || data.Total != 4 {
+		t.Errorf("limit=1: total %d, %d places", data.Total, len(data.Places))
+	}
+
+	for _, target := range []string{
+		"/api/places/bbox?br=55.74,37.73",
+		"/api/places/bbox?tl=55.76&br=55.74,37.73",
+		"/api/places/bbox?tl=95,37.70&br=55.74,37.73",
+		"/api/places/bbox?tl=55.74,37.70&br=55.76,37.73",
+		"/api/places/bbox?tl=55.76,37.70&br=55.74,37.73&limit=1001",
+	} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonClustersHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := JsonClustersHandler(store)
+
+	var data types.ClusterData
+	decodeJSON(t, get(handler, "/api/clusters"), &data)
+	if data.Precision != 5 || data.Total != 20 || len(data.Clusters) == 0 || data.Truncated {
+		t.Errorf("default: precision %d, total %d, %d clusters, truncated %v", data.Precision, data.Total, len(data.Clusters), data.Truncated)
+	}
+
+	data = types.ClusterData{}
+	decodeJSON(t, get(handler, "/api/clusters?precision=7&bbox=55.76,37.70,55.74,37.73"), &data)
+	if data.Precision != 7 || data.Total != 4 {
+		t.Errorf("bbox: precision %d, total %d", data.Precision, data.Total)
+	}
+
+	for _, target := range []string{
+		"/api/clusters?precision=0",
+		"/api/clusters?precision=13",
+		"/api/clusters?bbox=55.76,37.70",
+		"/api/clusters?bbox=55.74,37.70,55.76,37.73",
+	} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestJsonClustersHandlerTruncated(t *testing.T) {
+	// geohash_grid вернул не все ячейки: в них 5 мест из 7 найденных
+	store := newElasticsearchStore(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"timed_out":false,"hits":{"total":{"value":7}},"aggregations":{"clusters":{"buckets":[`+
+			`{"key":"ucfv0j","doc_count":3,"centroid":{"location":{"lat":55.75,"lon":37.61}}},`+
+			`{"key":"ucfv0k","doc_count":2,"centroid":{"location":{"lat":55.76,"lon":37.62}}}]}}}`)
+	})
+
+	var data types.ClusterData
+	decodeJSON(t, get(JsonClustersHandler(store), "/api/clusters?precision=6"), &data)
+	if data.Total != 7 || len(data.Clusters) != 2 || !data.Truncated {
+		t.Errorf("total %d, %d clusters, truncated %v; want 7, 2, true", data.Total, len(data.Clusters), data.Truncated)
+	}
+}
+
+func TestTileHandler(t *testing.T) {
+	store := newFakeStore(t)
+	store.Tile = []byte("fake tile")
+	handler := TileHandler(store)
+
+	rec := get(handler, "/tiles/10/619/320.pbf")
+	if rec.Code != http.StatusOK || rec.Body.String() != "fake tile" {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.mapbox-vector-tile" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	etag := rec.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, "/tiles/10/619/320.pbf", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if etag == "" || rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-None-Match %s: status %d, %d bytes", etag, rec.Code, rec.Body.Len())
+	}
+
+	for _, target := range []string{"/tiles/10/619/320.png", "/tiles/10/619.pbf", "/tiles/1/2/0.pbf", "/tiles/30/0/0.pbf"} {
+		if rec := get(handler, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	store := newFakeStore(t)
+	handler := ExportHandler(store)
+
+	rec := get(handler, "/api/export")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("ndjson: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	lines := 0
+	for scanner.Scan() {
+		var place types.Place
+		if err := json.Unmarshal(scanner.Bytes(), &place); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		lines++
+	}
+	if lines != 20 {
+		t.Errorf("ndjson: %d lines, want 20", lines)
+	}
+
+	rec = get(handler, "/api/export?format=geojson")
+	var collection struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil || collection.Type != "FeatureCollection" || len(collection.Features) != 20 {
+		t.Errorf("geojson: type %q, %d features, err %v", collection.Type, len(collection.Features), err)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "places.geojson") {
+		t.Errorf("geojson: Content-Disposition %q", cd)
+	}
+
+	rec = get(handler, "/api/export?format=csv")
+	if n := strings.Count(rec.Body.String(), "\n"); rec.Code != http.StatusOK || n != 21 {
+		t.Errorf("csv: status %d, %d lines, want header and 20 rows", rec.Code, n)
+	}
+
+	if rec := get(handler, "/api/export?format=xml"); rec.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: status %d, want 400", rec.Code)
+	}
+}
+
+// TestHandlersStoreErrors проверяет, что каждый обработчик отвечает на ошибку хранилища статусом ее класса
+func TestHandlersStoreErrors(t *testing.T) {
+	routes := []struct {
+		target  string
+		handler func(*fakestore.Store) http.Handler
+	}{
+		{"/api/places?page=1", func(s *fakestore.Store) http.Handler { return JsonHandler(s, testOptions) }},
+		{"/api/places?cursor=", func(s *fakestore.Store) http.Handler { return JsonHandler(s, testOptions) }},
+		{"/web/places?page=1", func(s *fakestore.Store) http.Handler { return HtmlHandler(s, testOptions) }},
+		{"/api/recommend?lat=55.7&lon=37.6", func(s *fakestore.Store) http.Handler { return JsonRecommendHandler(s) }},
+		{"/web/recommend?lat=55.7&lon=37.6", func(s *fakestore.Store) http.Handler { return HtmlRecommendHandler(s, testOptions) }},
+		{"/api/search?q=kafe", func(s *fakestore.Store) http.Handler { return JsonSearchHandler(s, testOptions) }},
+		{"/web/search?q=kafe", func(s *fakestore.Store) http.Handler { return HtmlSearchHandler(s, testOptions) }},
+		{"/api/suggest?prefix=kafe", func(s *fakestore.Store) http.Handler { return JsonSuggestHandler(s) }},
+		{"/api/place?id=1", func(s *fakestore.Store) http.Handler { return JsonPlaceHandler(s) }},
+		{"/api/places/bbox?tl=55.76,37.70&br=55.74,37.73", func(s *fakestore.Store) http.Handler { return JsonBoundingBoxHandler(s) }},
+		{"/api/clusters", func(s *fakestore.Store) http.Handler { return JsonClustersHandler(s) }},
+		{"/tiles/0/0/0.pbf", func(s *fakestore.Store) http.Handler { return TileHandler(s) }},
+		{"/api/export", func(s *fakestore.Store) http.Handler { return ExportHandler(s) }},
+	}
+	classes := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("%w: malformed query", db.ErrBadRequest), http.StatusBadRequest},
+		{fmt.Errorf("index places: %w", db.ErrNotFound), http.StatusNotFound},
+		{&db.Error{Op: "search", Kind: db.ErrUnavailable, Status: 429}, http.StatusServiceUnavailable},
+		{&db.Error{Op: "search", Kind: db.ErrTimeout, Status: 504}, http.StatusGatewayTimeout},
+	}
+
+	for _, route := range routes {
+		for _, class := range classes {
+			store := newFakeStore(t)
+			store.Err = class.err
+
+			rec := get(route.handler(store), route.target)
+			var body errorResponse
+			if rec.Code != class.status || json.Unmarshal(rec.Body.Bytes(), &body) != nil || body.Status != class.status {
+				t.Errorf("%s with %v: status %d, body %q; want %d", route.target, class.err, rec.Code, rec.Body, class.status)
+			}
+			if cd := rec.Header().Get("Content-Disposition"); cd != "" {
+				t.Errorf("%s with %v: error response is an attachment %q", route.target, class.err, cd)
+			}
+		}
+	}
+}
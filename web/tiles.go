@@ -0,0 +1,79 @@
+package web
+
+import (
+	"crypto/sha1"
+	"elasticTask/internal/db"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxTileZoom наибольший уровень масштаба, который принимает API _mvt
+const maxTileZoom = 29
+
+// parseTilePath разбирает путь вида /tiles/{z}/{x}/{y}.pbf
+func parseTilePath(path string) (z, x, y int, err error) {
+	rest := strings.TrimPrefix(path, "/tiles/")
+	if !strings.HasSuffix(rest, ".pbf") {
+		return 0, 0, 0, fmt.Errorf("tile path %q must end with .pbf", path)
+	}
+
+	parts := strings.Split(strings.TrimSuffix(rest, ".pbf"), "/")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("expected /tiles/{z}/{x}/{y}.pbf, got %q", path)
+	}
+
+	var coords [3]int
+	for i, part := range parts {
+		coords[i], err = strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid tile coordinate %q", part)
+		}
+	}
+
+	z, x, y = coords[0], coords[1], coords[2]
+	if z < 0 || z > maxTileZoom {
+		return 0, 0, 0, fmt.Errorf("zoom %d out of range", z)
+	}
+
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return 0, 0, 0, fmt.Errorf("tile %d/%d/%d out of range", z, x, y)
+	}
+	return z, x, y, nil
+}
+
+// TileHandler отдает векторные тайлы Mapbox со слоем мест
+func TileHandler(store db.TileStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		z, x, y, err := parseTilePath(r.URL.Path)
+		if err != nil {
+			http.Error(w, "Invalid tile: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tile, err := store.GetTile(r.Context(), z, x, y)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Тайл меняется только при переиндексации, поэтому его можно кэшировать и проверять по ETag
+		sum := sha1.Sum(tile)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Vary", "Accept-Encoding")
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+		w.Header().Set("Content-Length", strconv.Itoa(len(tile)))
+		w.Write(tile)
+	}
+}